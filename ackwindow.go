@@ -0,0 +1,63 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+// ackWindowSize is how many sent packets are remembered. ACKs for older packets are ignored.
+const ackWindowSize = 256
+
+// slotRef identifies the full slot data that was sent in a packet.
+// Slots are reused for other players, so the assignment generation is recorded as well.
+type slotRef struct {
+	index    int
+	gen      uint32
+	updateID uint8
+}
+
+type ackEntry struct {
+	seq   uint16
+	valid bool
+	slots []slotRef
+}
+
+// ackWindow remembers which slots carried full slot data in each recently sent packet.
+// The client echoes the sequence number of the latest packet it received, so any echo
+// proves that everything sent in that particular packet arrived, even if ACKs come back
+// reordered, some are lost or the sequence number wrapped around.
+//
+// ACKs are matched exactly rather than cumulatively: the echo is just the newest
+// sequence number the client has seen, not a claim that it received everything before
+// it. Treating it as covering earlier packets would mark a lost full slot as delivered
+// as soon as any later position packet got through, and the slot would never be resent.
+type ackWindow struct {
+	entries [ackWindowSize]ackEntry
+}
+
+// record remembers the slots that were sent in full with seq.
+func (w *ackWindow) record(seq uint16, slots []slotRef) {
+	entry := &w.entries[seq%ackWindowSize]
+	entry.seq = seq
+	entry.valid = true
+	entry.slots = append(entry.slots[:0], slots...)
+}
+
+// ack marks the slots that were sent in full with seq as delivered, unless they have
+// been reassigned or their data has changed since.
+func (w *ackWindow) ack(seq uint16, slots []*slotInfo) {
+	entry := &w.entries[seq%ackWindowSize]
+	if !entry.valid || entry.seq != seq {
+		return
+	}
+	for _, ref := range entry.slots {
+		if ref.index >= len(slots) {
+			continue
+		}
+		slot := slots[ref.index]
+		if slot != nil && slot.gen == ref.gen && slot.LastUpdateID == ref.updateID {
+			slot.UpdateACKed = true
+		}
+	}
+	// Duplicate ACKs for this packet carry no new information.
+	entry.valid = false
+}
@@ -0,0 +1,119 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+// ackTestClient returns a client with another ready player in its first slot.
+func ackTestClient(t *testing.T) (*Client, *Client) {
+	rng := rand.New(rand.NewSource(1))
+	other := newClient(ClientConfig{MaxVisiblePlayers: 1})
+	other.chanInfo = testChanInfo("channel")
+	other.playerInfo = testPlayerInfo("other", 2)
+	if err := other.carPos.Update(testCarPos(rng)); err != nil {
+		t.Fatal(err)
+	}
+	other.publish()
+
+	c := newClient(ClientConfig{MaxVisiblePlayers: 2})
+	c.addSlot(other)
+	return c, other
+}
+
+// sendSlots writes a slot packet and returns whether the first slot was sent in full.
+func sendSlots(c *Client, seq uint16, now time.Time) bool {
+	c.net.onSend(seq, now)
+	c.writeSlots(new(bytes.Buffer), seq, now)
+	return c.slots[0].PacketSentSeq == seq && c.slots[0].PacketSentTime.Equal(now)
+}
+
+func TestAckWindowLoss(t *testing.T) {
+	c, _ := ackTestClient(t)
+	now := time.Now()
+	if !sendSlots(c, 1, now) {
+		t.Fatal("Expected first slot packet to be sent in full")
+	}
+	// The full packet is lost; only a later position packet gets ACKed, which says
+	// nothing about the earlier one.
+	sendSlots(c, 2, now.Add(50*time.Millisecond))
+	c.acks.ack(2, c.slots)
+	if c.slots[0].UpdateACKed {
+		t.Error("Expected ACK of a position-only packet to not cover the full slot")
+	}
+	if sendSlots(c, 3, now.Add(100*time.Millisecond)) {
+		t.Error("Expected no retransmission before the timeout")
+	}
+	if !sendSlots(c, 4, now.Add(initialRTO+time.Millisecond)) {
+		t.Error("Expected retransmission after the timeout")
+	}
+	c.acks.ack(4, c.slots)
+	if !c.slots[0].UpdateACKed {
+		t.Error("Expected ACK of the retransmission to cover the slot")
+	}
+	if sendSlots(c, 5, now.Add(3*initialRTO)) {
+		t.Error("Expected no retransmission after the slot was ACKed")
+	}
+}
+
+func TestAckWindowReordering(t *testing.T) {
+	c, _ := ackTestClient(t)
+	now := time.Now()
+	sendSlots(c, 10, now)
+	sendSlots(c, 11, now)
+	sendSlots(c, 12, now)
+	// ACKs arrive out of order; the late ACK for the full packet still counts.
+	c.acks.ack(12, c.slots)
+	c.acks.ack(11, c.slots)
+	if c.slots[0].UpdateACKed {
+		t.Error("Expected slot to be unACKed before the full packet's ACK arrives")
+	}
+	c.acks.ack(10, c.slots)
+	if !c.slots[0].UpdateACKed {
+		t.Error("Expected reordered ACK to cover the slot")
+	}
+}
+
+func TestAckWindowWraparound(t *testing.T) {
+	c, _ := ackTestClient(t)
+	now := time.Now()
+	// A stale entry from the previous sequence cycle must not match.
+	c.acks.record(65535-ackWindowSize, []slotRef{{index: 0, gen: c.slots[0].gen, updateID: 1}})
+	sendSlots(c, 65535, now)
+	sendSlots(c, 0, now)
+	sendSlots(c, 1, now)
+	c.acks.ack(65535-ackWindowSize, c.slots)
+	if c.slots[0].UpdateACKed {
+		t.Error("Expected stale ACK from the previous cycle to be ignored")
+	}
+	c.acks.ack(65535, c.slots)
+	if !c.slots[0].UpdateACKed {
+		t.Error("Expected ACK across the wraparound to cover the slot")
+	}
+}
+
+func TestAckWindowInfoChange(t *testing.T) {
+	c, other := ackTestClient(t)
+	now := time.Now()
+	sendSlots(c, 1, now)
+	other.registerUpdate()
+	other.publish()
+	if !sendSlots(c, 2, now) {
+		t.Fatal("Expected changed player info to be resent in full")
+	}
+	// The ACK for the old data must not mark the new data as delivered.
+	c.acks.ack(1, c.slots)
+	if c.slots[0].UpdateACKed {
+		t.Error("Expected ACK of outdated data to be ignored")
+	}
+	c.acks.ack(2, c.slots)
+	if !c.slots[0].UpdateACKed {
+		t.Error("Expected ACK of the new data to cover the slot")
+	}
+}
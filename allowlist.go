@@ -0,0 +1,162 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"sync"
+)
+
+var ErrInvalidIP = errors.New("invalid IP address")
+
+// PersonaAllowlist restricts which personas may join the server.
+//
+// The global list applies to every client, and a per-IP list applies to clients
+// connecting from that IP. A persona is allowed if it is on the global list or on
+// the list of its IP. Clients are only restricted while at least one list applies
+// to them, so an empty allowlist lets everyone in.
+type PersonaAllowlist struct {
+	sync.RWMutex
+	global map[int]struct{}
+	byIP   map[string]map[int]struct{}
+}
+
+// NewPersonaAllowlist creates an allowlist from config. Lists for invalid IPs are
+// skipped; the returned allowlist is usable even if an error is returned.
+func NewPersonaAllowlist(config AllowlistConfig) (*PersonaAllowlist, error) {
+	l := &PersonaAllowlist{
+		global: make(map[int]struct{}),
+		byIP:   make(map[string]map[int]struct{}),
+	}
+	l.Set("", config.Personas)
+	var err error
+	for ip, personas := range config.PersonasByIP {
+		if setErr := l.Set(ip, personas); setErr != nil && err == nil {
+			err = fmt.Errorf("allowlist for %q: %w", ip, setErr)
+		}
+	}
+	return l, err
+}
+
+// list returns the set for ip, or the global set if ip is empty.
+// If create is set, a missing per-IP set is added.
+func (l *PersonaAllowlist) list(ip string, create bool) (map[int]struct{}, error) {
+	if ip == "" {
+		return l.global, nil
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, ErrInvalidIP
+	}
+	ip = parsed.String()
+	set, ok := l.byIP[ip]
+	if !ok && create {
+		set = make(map[int]struct{})
+		l.byIP[ip] = set
+	}
+	return set, nil
+}
+
+// Set replaces the list for ip, or the global list if ip is empty.
+// An empty list removes the restriction.
+func (l *PersonaAllowlist) Set(ip string, personas []int) error {
+	l.Lock()
+	defer l.Unlock()
+	set, err := l.list(ip, true)
+	if err != nil {
+		return err
+	}
+	for id := range set {
+		delete(set, id)
+	}
+	for _, id := range personas {
+		set[id] = struct{}{}
+	}
+	l.compact()
+	return nil
+}
+
+// Add allows personas on the list for ip, or on the global list if ip is empty.
+func (l *PersonaAllowlist) Add(ip string, personas ...int) error {
+	l.Lock()
+	defer l.Unlock()
+	set, err := l.list(ip, true)
+	if err != nil {
+		return err
+	}
+	for _, id := range personas {
+		set[id] = struct{}{}
+	}
+	l.compact()
+	return nil
+}
+
+// Remove takes personas off the list for ip, or off the global list if ip is empty.
+func (l *PersonaAllowlist) Remove(ip string, personas ...int) error {
+	l.Lock()
+	defer l.Unlock()
+	set, err := l.list(ip, false)
+	if err != nil {
+		return err
+	}
+	for _, id := range personas {
+		delete(set, id)
+	}
+	l.compact()
+	return nil
+}
+
+// compact drops empty per-IP lists so they no longer restrict their IP.
+func (l *PersonaAllowlist) compact() {
+	for ip, set := range l.byIP {
+		if len(set) == 0 {
+			delete(l.byIP, ip)
+		}
+	}
+}
+
+// Allowed returns true if personaID may connect from ip. A nil allowlist allows everyone.
+func (l *PersonaAllowlist) Allowed(ip net.IP, personaID int) bool {
+	if l == nil {
+		return true
+	}
+	l.RLock()
+	defer l.RUnlock()
+	ipSet, restricted := l.byIP[ip.String()]
+	if len(l.global) == 0 && !restricted {
+		return true
+	}
+	if _, ok := l.global[personaID]; ok {
+		return true
+	}
+	_, ok := ipSet[personaID]
+	return ok
+}
+
+// Config returns the current lists in the form used by the config file.
+func (l *PersonaAllowlist) Config() AllowlistConfig {
+	l.RLock()
+	defer l.RUnlock()
+	config := AllowlistConfig{
+		Personas:     sortedIDs(l.global),
+		PersonasByIP: make(map[string][]int, len(l.byIP)),
+	}
+	for ip, set := range l.byIP {
+		config.PersonasByIP[ip] = sortedIDs(set)
+	}
+	return config
+}
+
+func sortedIDs(set map[int]struct{}) []int {
+	ids := make([]int, 0, len(set))
+	for id := range set {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
@@ -0,0 +1,77 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"math/rand"
+	"net"
+	"testing"
+)
+
+func TestPersonaAllowlist(t *testing.T) {
+	l, err := NewPersonaAllowlist(AllowlistConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lan := net.IPv4(10, 0, 0, 1)
+	wan := net.IPv4(192, 0, 2, 1)
+	if !l.Allowed(wan, 1) {
+		t.Error("Expected empty allowlist to allow everyone")
+	}
+
+	if err := l.Set("10.0.0.1", []int{2}); err != nil {
+		t.Fatal(err)
+	}
+	if l.Allowed(lan, 1) || !l.Allowed(lan, 2) {
+		t.Error("Expected per-IP list to restrict its IP")
+	}
+	if !l.Allowed(wan, 1) {
+		t.Error("Expected per-IP list to not restrict other IPs")
+	}
+
+	l.Add("", 1)
+	if !l.Allowed(lan, 1) || !l.Allowed(lan, 2) {
+		t.Error("Expected global and per-IP lists to be combined")
+	}
+	if !l.Allowed(wan, 1) || l.Allowed(wan, 2) {
+		t.Error("Expected global list to restrict all IPs")
+	}
+
+	l.Remove("", 1)
+	l.Remove("10.0.0.1", 2)
+	if !l.Allowed(wan, 3) || !l.Allowed(lan, 3) {
+		t.Error("Expected emptied allowlist to allow everyone")
+	}
+	if err := l.Add("not an ip", 1); err != ErrInvalidIP {
+		t.Errorf("Expected ErrInvalidIP, got %v", err)
+	}
+}
+
+func TestAllowlistKicksClients(t *testing.T) {
+	config := DefaultConfig()
+	config.UDP.Workers = 2
+	config.Allowlist.Personas = []int{1, 2, 3}
+	i := newTestServerWithConfig(t, config)
+	populate(i, 5, rand.New(rand.NewSource(1)))
+	if count := i.ClientCount(); count != 3 {
+		t.Errorf("Expected only the 3 allowed personas to stay connected, got %d", count)
+	}
+
+	if err := i.DisallowPersonas("", 3); err != nil {
+		t.Fatal(err)
+	}
+	if count := i.ClientCount(); count != 2 {
+		t.Errorf("Expected disallowed persona to be kicked, got %d clients", count)
+	}
+
+	// An allowlist for the test IP lets persona 4 back in from there.
+	if err := i.AllowPersonas("127.0.0.1", 4); err != nil {
+		t.Fatal(err)
+	}
+	populate(i, 5, rand.New(rand.NewSource(1)))
+	if count := i.ClientCount(); count != 3 {
+		t.Errorf("Expected personas 1, 2 and 4 to be connected, got %d", count)
+	}
+}
@@ -0,0 +1,193 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/WorldUnitedNFS/freeroam/math"
+)
+
+type AnomalyKind string
+
+const (
+	AnomalySpeed      AnomalyKind = "speed"
+	AnomalyTeleport   AnomalyKind = "teleport"
+	AnomalyOutOfWorld AnomalyKind = "out_of_world"
+)
+
+// AnomalyAction is what the server does to a client that triggered an anomaly.
+type AnomalyAction string
+
+const (
+	ActionLog  AnomalyAction = "log"
+	ActionHide AnomalyAction = "hide"
+	ActionKick AnomalyAction = "kick"
+)
+
+var ErrInvalidAnomalyAction = errors.New("invalid anomaly action")
+
+// Validate returns ErrInvalidAnomalyAction unless all actions are known.
+func (c AnomalyConfig) Validate() error {
+	actions := []struct {
+		name   string
+		action AnomalyAction
+	}{
+		{"SpeedAction", c.SpeedAction},
+		{"TeleportAction", c.TeleportAction},
+		{"OutOfWorldAction", c.OutOfWorldAction},
+	}
+	for _, a := range actions {
+		switch a.action {
+		case ActionLog, ActionHide, ActionKick:
+		default:
+			return fmt.Errorf("%w %q for %v", ErrInvalidAnomalyAction, a.action, a.name)
+		}
+	}
+	return nil
+}
+
+// AnomalyEvent describes a suspicious position update.
+type AnomalyEvent struct {
+	Kind        AnomalyKind   `json:"kind"`
+	Action      AnomalyAction `json:"action"`
+	Time        time.Time     `json:"time"`
+	Addr        string        `json:"addr"`
+	PersonaID   int           `json:"personaId"`
+	PersonaName string        `json:"personaName"`
+	Pos         math.Vector3D `json:"pos"`
+	PrevPos     math.Vector3D `json:"prevPos"`
+	// Distance is the displacement since the previous update in meters.
+	Distance float64 `json:"distance"`
+	// ElapsedMs is the simulation time since the previous update.
+	ElapsedMs int `json:"elapsedMs"`
+	// Speed is the implied speed for teleports, and the highest of the implied
+	// and reported speeds for speed anomalies, in m/s.
+	Speed float64 `json:"speed"`
+}
+
+// anomalyTracker keeps the previous position update of a client.
+type anomalyTracker struct {
+	valid   bool
+	coords  math.Vector3D
+	simTime uint16
+}
+
+// maxAnomalyGapMs is the largest simulation time gap between two updates that is still
+// compared; longer gaps (loading screens, lag spikes) reset the tracker.
+const maxAnomalyGapMs = 2000
+
+// minSpeedSampleMs is the smallest simulation time gap used to compute an implied speed,
+// so that jitter between updates sent close together isn't mistaken for speed.
+const minSpeedSampleMs = 100
+
+// check compares a new position update against the previous one and returns the anomalies found.
+func (t *anomalyTracker) check(config AnomalyConfig, pos *CarPosPacket) []AnomalyEvent {
+	events := make([]AnomalyEvent, 0)
+	coords := pos.Coordinates()
+	if config.WorldMin != config.WorldMax && !insideBox(coords, config.WorldMin, config.WorldMax) {
+		events = append(events, AnomalyEvent{Kind: AnomalyOutOfWorld, Action: config.OutOfWorldAction, Pos: coords})
+	}
+	prev, prevTime := t.coords, t.simTime
+	valid := t.valid
+	t.valid, t.coords, t.simTime = true, coords, pos.SimTime()
+	if !valid {
+		return events
+	}
+
+	elapsed := int(pos.SimTime() - prevTime)
+	if elapsed > maxAnomalyGapMs {
+		return events
+	}
+	distance := math.Distance3D(prev, coords)
+	implied := 0.0
+	if elapsed > 0 {
+		implied = distance / (float64(elapsed) / 1000)
+	}
+	base := AnomalyEvent{
+		Pos:       coords,
+		PrevPos:   prev,
+		Distance:  distance,
+		ElapsedMs: elapsed,
+	}
+	if config.TeleportDistance > 0 && distance >= config.TeleportDistance {
+		ev := base
+		ev.Kind, ev.Action, ev.Speed = AnomalyTeleport, config.TeleportAction, implied
+		return append(events, ev)
+	}
+	if config.MaxSpeed > 0 {
+		speed := math.Magnitude(pos.Velocity())
+		if elapsed >= minSpeedSampleMs && implied > speed {
+			speed = implied
+		}
+		if speed > config.MaxSpeed {
+			ev := base
+			ev.Kind, ev.Action, ev.Speed = AnomalySpeed, config.SpeedAction, speed
+			events = append(events, ev)
+		}
+	}
+	return events
+}
+
+func insideBox(v, min, max math.Vector3D) bool {
+	return v.X >= min.X && v.X <= max.X &&
+		v.Y >= min.Y && v.Y <= max.Y &&
+		v.Z >= min.Z && v.Z <= max.Z
+}
+
+// checkAnomalies runs the anomaly detector on the client's latest position update
+// and applies the configured actions. It returns false if the client was kicked.
+func (c *Client) checkAnomalies(now time.Time) bool {
+	server := c.shard.server
+	config := server.anomalyConfig()
+	if !config.Enabled {
+		return true
+	}
+	for _, ev := range c.anomalies.check(config, &c.carPos) {
+		ev.Time = now
+		ev.Addr = c.Addr.String()
+		ev.PersonaID = c.personaID
+		ev.PersonaName = c.PersonaName
+		server.reportAnomaly(ev)
+		switch ev.Action {
+		case ActionHide:
+			c.hiddenUntil = now.Add(time.Duration(config.HideDurationSec) * time.Second)
+		case ActionKick:
+			log.Printf("Kicking %v (persona %v); %v anomaly", ev.Addr, ev.PersonaID, ev.Kind)
+			c.detach()
+			return false
+		}
+	}
+	return true
+}
+
+// OnAnomaly registers a handler that receives every anomaly event.
+// Handlers are called from shard goroutines and must not block.
+func (i *Server) OnAnomaly(fn func(AnomalyEvent)) {
+	i.configLock.Lock()
+	defer i.configLock.Unlock()
+	i.anomalyHandlers = append(i.anomalyHandlers, fn)
+}
+
+func (i *Server) anomalyConfig() AnomalyConfig {
+	i.configLock.RLock()
+	defer i.configLock.RUnlock()
+	return i.config.Anomaly
+}
+
+func (i *Server) reportAnomaly(ev AnomalyEvent) {
+	encoded, _ := json.Marshal(ev)
+	log.Printf("Anomaly: %s", encoded)
+	i.configLock.RLock()
+	handlers := i.anomalyHandlers
+	i.configLock.RUnlock()
+	for _, fn := range handlers {
+		fn(ev)
+	}
+}
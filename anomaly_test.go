@@ -0,0 +1,122 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"errors"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/WorldUnitedNFS/freeroam/math"
+)
+
+func testPos(simTime uint16, x, y float64, speed float64) *CarPosPacket {
+	return &CarPosPacket{
+		time:     simTime,
+		coords:   math.Vector3D{X: x, Y: y},
+		velocity: math.Vector3D{X: speed},
+	}
+}
+
+func TestAnomalyTracker(t *testing.T) {
+	config := DefaultConfig().Anomaly
+	config.WorldMin = math.Vector3D{X: -1000, Y: -1000, Z: -100}
+	config.WorldMax = math.Vector3D{X: 1000, Y: 1000, Z: 100}
+
+	var tracker anomalyTracker
+	if events := tracker.check(config, testPos(0, 0, 0, 30)); len(events) != 0 {
+		t.Errorf("Expected no anomalies for the first update, got %v", events)
+	}
+	if events := tracker.check(config, testPos(1000, 40, 0, 40)); len(events) != 0 {
+		t.Errorf("Expected no anomalies for normal driving, got %v", events)
+	}
+	if events := tracker.check(config, testPos(1500, 140, 0, 40)); len(events) != 1 || events[0].Kind != AnomalySpeed {
+		t.Errorf("Expected a speed anomaly for 200 m/s of movement, got %v", events)
+	}
+	if events := tracker.check(config, testPos(1600, 145, 0, 250)); len(events) != 1 || events[0].Kind != AnomalySpeed {
+		t.Errorf("Expected a speed anomaly for a reported speed of 250 m/s, got %v", events)
+	}
+	if events := tracker.check(config, testPos(1700, 900, 0, 0)); len(events) != 1 || events[0].Kind != AnomalyTeleport {
+		t.Errorf("Expected a teleport anomaly, got %v", events)
+	}
+	if events := tracker.check(config, testPos(2700, 1010, 0, 0)); len(events) != 1 || events[0].Kind != AnomalyOutOfWorld {
+		t.Errorf("Expected an out of world anomaly, got %v", events)
+	}
+	if events := tracker.check(config, testPos(65000, 0, 0, 0)); len(events) != 0 {
+		t.Errorf("Expected long gaps to reset the tracker, got %v", events)
+	}
+	if events := tracker.check(config, testPos(464, 600, 0, 0)); len(events) != 1 || events[0].Kind != AnomalyTeleport {
+		t.Errorf("Expected sim time wraparound to be handled, got %v", events)
+	}
+}
+
+func TestAnomalyConfigValidate(t *testing.T) {
+	config := DefaultConfig()
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected the default config to be valid, got %v", err)
+	}
+	config.Anomaly.TeleportAction = "ban"
+	if err := config.Validate(); !errors.Is(err, ErrInvalidAnomalyAction) {
+		t.Errorf("Expected ErrInvalidAnomalyAction, got %v", err)
+	}
+	if i := NewServer(config); i.anomalyConfig().Enabled {
+		t.Error("Expected anomaly detection to be disabled with an unknown action")
+	}
+}
+
+// teleport moves the client at testAddr(n) by 900m within 100ms and returns
+// whether it is still connected according to checkAnomalies.
+func teleport(i *Server, n int, now time.Time) bool {
+	ok := true
+	i.execClient(testAddr(n).String(), func(client *Client) {
+		client.anomalies = anomalyTracker{}
+		client.carPos = *testPos(0, 0, 0, 0)
+		client.checkAnomalies(now)
+		client.carPos = *testPos(100, 900, 0, 0)
+		ok = client.checkAnomalies(now)
+		client.publish()
+	})
+	return ok
+}
+
+func TestAnomalyActions(t *testing.T) {
+	config := DefaultConfig()
+	config.UDP.Workers = 2
+	config.UDP.DisableRadiusSync = true
+	config.Anomaly.TeleportAction = ActionHide
+	config.Anomaly.HideDurationSec = 30
+	i := newTestServerWithConfig(t, config)
+	var events []AnomalyEvent
+	i.OnAnomaly(func(ev AnomalyEvent) {
+		events = append(events, ev)
+	})
+	populate(i, 2, rand.New(rand.NewSource(1)))
+	client := testClient(i, 0)
+
+	now := time.Now()
+	if !teleport(i, 0, now) {
+		t.Fatal("Expected the hide action to keep the client connected")
+	}
+	if len(events) != 1 || events[0].PersonaID != 1 || events[0].PersonaName != "player0" {
+		t.Errorf("Expected a teleport event for persona 1, got %+v", events)
+	}
+	if until := client.State().hiddenUntil; !until.Equal(now.Add(30 * time.Second)) {
+		t.Errorf("Expected the client to be hidden for 30s, got until %v", until)
+	}
+	if visible := visibleTo(i, 1); visible[client] {
+		t.Error("Expected the hidden client not to be visible")
+	}
+
+	i.configLock.Lock()
+	i.config.Anomaly.TeleportAction = ActionKick
+	i.configLock.Unlock()
+	if teleport(i, 0, now) {
+		t.Error("Expected the kick action to report the client as kicked")
+	}
+	if count := i.ClientCount(); count != 1 {
+		t.Errorf("Expected the kicked client to be removed, got %d clients", count)
+	}
+}
@@ -0,0 +1,61 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+)
+
+type allowlistRequest struct {
+	IP       string `json:"ip"`
+	Personas []int  `json:"personas"`
+}
+
+type allowlistResponse struct {
+	Personas     []int            `json:"personas"`
+	PersonasByIP map[string][]int `json:"personasByIp"`
+}
+
+// handleAllowlist returns the persona allowlist on GET, replaces a list on PUT, adds to it on POST
+// and removes a persona on DELETE. Lists are selected by the "ip" field or query parameter;
+// without it the global list is used.
+func (s *Server) handleAllowlist(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		config := s.i.Allowlist.Config()
+		writeJSON(w, http.StatusOK, allowlistResponse{Personas: config.Personas, PersonasByIP: config.PersonasByIP})
+	case http.MethodPut, http.MethodPost:
+		var req allowlistRequest
+		if err := readJSON(w, r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		var err error
+		if r.Method == http.MethodPut {
+			err = s.i.SetAllowedPersonas(req.IP, req.Personas)
+		} else {
+			err = s.i.AllowPersonas(req.IP, req.Personas...)
+		}
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		personaID, err := strconv.Atoi(r.URL.Query().Get("personaId"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "personaId is required")
+			return
+		}
+		if err := s.i.DisallowPersonas(r.URL.Query().Get("ip"), personaID); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete)
+	}
+}
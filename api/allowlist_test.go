@@ -0,0 +1,31 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestAllowlist(t *testing.T) {
+	i, s, addr := newTestAPI(t)
+	connectTestClient(t, i, addr, 7)
+
+	expectStatus(t, do(s, http.MethodPut, "/allowlist", `{"personas": [1, 2, 7]}`), http.StatusNoContent)
+	expectStatus(t, do(s, http.MethodPost, "/allowlist", `{"ip": "192.0.2.1", "personas": [3]}`), http.StatusNoContent)
+	expectStatus(t, do(s, http.MethodPost, "/allowlist", `{"ip": "not an ip", "personas": [3]}`), http.StatusBadRequest)
+	if config := i.Allowlist.Config(); !slices.Equal(config.PersonasByIP["192.0.2.1"], []int{3}) {
+		t.Errorf("Expected a list for 192.0.2.1, got %+v", config)
+	}
+	if count := i.ClientCount(); count != 1 {
+		t.Errorf("Expected the allowed client to stay, got %d clients", count)
+	}
+
+	expectStatus(t, do(s, http.MethodDelete, "/allowlist?personaId=7", ""), http.StatusNoContent)
+	if count := i.ClientCount(); count != 0 {
+		t.Errorf("Expected the client that is no longer allowed to be kicked, got %d clients", count)
+	}
+}
@@ -0,0 +1,108 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package api implements the HTTP API used by the core server to manage freeroamd at runtime.
+package api
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/WorldUnitedNFS/freeroam"
+)
+
+var ErrTokenRequired = errors.New("api: a token is required unless the API listens on a unix socket")
+
+// ValidateConfig refuses API configs that would let anyone who can reach a TCP
+// listener manage the server.
+func ValidateConfig(config freeroam.APIConfig) error {
+	if config.Token == "" && !strings.HasPrefix(config.ListenAddress, "unix:") {
+		return ErrTokenRequired
+	}
+	return nil
+}
+
+func NewServer(i *freeroam.Server, config freeroam.APIConfig) *Server {
+	s := &Server{
+		i:      i,
+		config: config,
+		mux:    http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/keys", s.handleKeys)
+	s.mux.HandleFunc("/allowlist", s.handleAllowlist)
+	s.mux.HandleFunc("/bans", s.handleBans)
+	s.mux.HandleFunc("/players", s.handlePlayers)
+	s.mux.Handle("/kick", moderationHandler(s.handleKick))
+	s.mux.Handle("/hide", moderationHandler(s.handleHide))
+	s.mux.Handle("/ghost", moderationHandler(s.handleGhost))
+	s.mux.HandleFunc("/spectators", s.handleSpectators)
+	s.mux.HandleFunc("/groups", s.handleGroups)
+	s.mux.HandleFunc("/blocks", s.handleBlocks)
+	s.mux.HandleFunc("/slots", s.handleSlots)
+	return s
+}
+
+type Server struct {
+	i      *freeroam.Server
+	config freeroam.APIConfig
+	mux    *http.ServeMux
+}
+
+// ServeHTTP checks the bearer token and dispatches the request.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.config.Token != "" {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.config.Token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "invalid token")
+			return
+		}
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// Listen opens the configured listener. Addresses starting with "unix:" are unix socket paths.
+func Listen(addr string) (net.Listener, error) {
+	if path := strings.TrimPrefix(addr, "unix:"); path != addr {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		return net.Listen("unix", path)
+	}
+	return net.Listen("tcp", addr)
+}
+
+func (s *Server) ttl(seconds int) time.Duration {
+	if seconds <= 0 {
+		seconds = s.config.SessionKeyTTL
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+func readJSON(w http.ResponseWriter, r *http.Request, v interface{}) error {
+	defer r.Body.Close()
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<16))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+}
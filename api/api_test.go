@@ -0,0 +1,271 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/WorldUnitedNFS/freeroam"
+)
+
+const testToken = "secret"
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func testConfig() freeroam.Config {
+	config := freeroam.DefaultConfig()
+	config.UDP.Workers = 2
+	config.Bans.File = ""
+	return config
+}
+
+// newTestAPI creates an API for a server listening on a local UDP port, which is
+// returned so that clients can connect with connectTestClient.
+func newTestAPI(t *testing.T) (*freeroam.Server, *Server, *net.UDPAddr) {
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := conn.LocalAddr().(*net.UDPAddr)
+	conn.Close()
+	config := testConfig()
+	config.UDP.ListenAddress = addr.String()
+	i := freeroam.NewServer(config)
+	served := make(chan error, 1)
+	go func() {
+		served <- i.Serve(context.Background())
+	}()
+	t.Cleanup(func() {
+		i.Shutdown(context.Background())
+		<-served
+	})
+	return i, NewServer(i, freeroam.APIConfig{Token: testToken, SessionKeyTTL: 60}), addr
+}
+
+// connectTestClient connects a client with personaID to the server at addr and waits
+// until it shows up in the player list.
+func connectTestClient(t *testing.T, i *freeroam.Server, addr *net.UDPAddr, personaID int) *net.UDPConn {
+	conn, err := net.DialUDP("udp", nil, addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	hello := make([]byte, 58)
+	hello[2] = 0x06
+	chanInfo := make([]byte, 34)
+	copy(chanInfo[2:], "channel")
+	playerInfo := make([]byte, 64)
+	copy(playerInfo[1:33], "player")
+	binary.LittleEndian.PutUint32(playerInfo[41:45], uint32(personaID))
+	buf := new(bytes.Buffer)
+	buf.Write(make([]byte, 16))
+	freeroam.WriteSubpacket(buf, 0x00, chanInfo)
+	freeroam.WriteSubpacket(buf, 0x01, playerInfo)
+	freeroam.WriteSubpacket(buf, 0x12, make([]byte, 24))
+	buf.Write([]byte{0xff, 0x00, 0x00, 0x00, 0x00})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		conn.Write(hello)
+		time.Sleep(10 * time.Millisecond)
+		conn.Write(buf.Bytes())
+		time.Sleep(50 * time.Millisecond)
+		for _, p := range i.Players() {
+			if p.PersonaID == personaID {
+				return conn
+			}
+		}
+	}
+	t.Fatalf("Client with persona %d didn't connect", personaID)
+	return nil
+}
+
+// do sends a request with the test token to s and returns the response.
+func do(s *Server, method, target, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	r.Header.Set("Authorization", "Bearer "+testToken)
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, r)
+	return w
+}
+
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Errorf("Expected status %d, got %d: %s", status, w.Code, w.Body.String())
+	}
+}
+
+func decode(t *testing.T, w *httptest.ResponseRecorder, v interface{}) {
+	t.Helper()
+	if err := json.NewDecoder(w.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAuth(t *testing.T) {
+	_, s, _ := newTestAPI(t)
+	for _, header := range []string{"", "Bearer wrong", testToken} {
+		r := httptest.NewRequest(http.MethodGet, "/keys", nil)
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, r)
+		expectStatus(t, w, http.StatusUnauthorized)
+	}
+	expectStatus(t, do(s, http.MethodGet, "/keys", ""), http.StatusMethodNotAllowed)
+}
+
+func TestValidateConfig(t *testing.T) {
+	tests := []struct {
+		config freeroam.APIConfig
+		valid  bool
+	}{
+		{freeroam.APIConfig{ListenAddress: "127.0.0.1:8080"}, false},
+		{freeroam.APIConfig{ListenAddress: "127.0.0.1:8080", Token: testToken}, true},
+		{freeroam.APIConfig{ListenAddress: "unix:/run/freeroamd.sock"}, true},
+	}
+	for _, test := range tests {
+		if err := ValidateConfig(test.config); (err == nil) != test.valid {
+			t.Errorf("Expected %+v to be valid: %v, got %v", test.config, test.valid, err)
+		}
+	}
+}
+
+// badRequestTargets lists the endpoints that take a JSON body on POST.
+var badRequestTargets = []string{"/keys", "/allowlist", "/bans", "/kick", "/hide", "/ghost", "/spectators", "/groups", "/blocks", "/slots"}
+
+func TestBadRequests(t *testing.T) {
+	_, s, _ := newTestAPI(t)
+	for _, target := range badRequestTargets {
+		expectStatus(t, do(s, http.MethodPost, target, "{"), http.StatusBadRequest)
+		expectStatus(t, do(s, http.MethodPost, target, `{"unknown": 1}`), http.StatusBadRequest)
+	}
+}
+
+// resourceTests lists the endpoints that add an entry on POST and remove it on DELETE.
+var resourceTests = []struct {
+	target string
+	// body adds the entry.
+	body string
+	// list lists the entries and listed is the part of the listing that shows the entry.
+	// Both are empty if the endpoint can't list entries.
+	list, listed string
+	// remove is the query that identifies the entry on DELETE.
+	remove string
+	// missing is the status of removing an entry that doesn't exist.
+	missing int
+}{
+	{
+		target:  "/keys",
+		body:    `{"address": "192.0.2.1:1234", "key": "FMb88lBCpYFqrEzsigPNVA=="}`,
+		remove:  "address=192.0.2.1:1234",
+		missing: http.StatusNotFound,
+	},
+	{
+		target:  "/allowlist",
+		body:    `{"personas": [7]}`,
+		list:    "/allowlist",
+		listed:  `"personas":[7]`,
+		remove:  "personaId=7",
+		missing: http.StatusNoContent,
+	},
+	{
+		target:  "/bans",
+		body:    `{"network": "192.0.2.0/24"}`,
+		list:    "/bans",
+		listed:  `"id":"192.0.2.0/24"`,
+		remove:  "id=192.0.2.0/24",
+		missing: http.StatusNotFound,
+	},
+	{
+		target:  "/spectators",
+		body:    `{"personaId": 7, "follow": 3}`,
+		list:    "/spectators",
+		listed:  `"personaId":7`,
+		remove:  "personaId=7",
+		missing: http.StatusNotFound,
+	},
+	{
+		target:  "/groups",
+		body:    `{"id": "party:1", "members": [1, 2]}`,
+		list:    "/groups",
+		listed:  `"id":"party:1"`,
+		remove:  "id=party:1",
+		missing: http.StatusNotFound,
+	},
+	{
+		target:  "/blocks",
+		body:    `{"personaId": 1, "blockedId": 4}`,
+		list:    "/blocks?personaId=1",
+		listed:  `"blocked":[4]`,
+		remove:  "personaId=1&blockedId=4",
+		missing: http.StatusNotFound,
+	},
+	{
+		target:  "/slots",
+		body:    `{"personaId": 7, "maxVisiblePlayers": 4}`,
+		list:    "/slots",
+		listed:  `"personaId":7`,
+		remove:  "personaId=7",
+		missing: http.StatusNotFound,
+	},
+}
+
+func TestResources(t *testing.T) {
+	_, s, _ := newTestAPI(t)
+	listed := func(test int) bool {
+		w := do(s, http.MethodGet, resourceTests[test].list, "")
+		expectStatus(t, w, http.StatusOK)
+		return strings.Contains(w.Body.String(), resourceTests[test].listed)
+	}
+	for n, test := range resourceTests {
+		if w := do(s, http.MethodPost, test.target, test.body); w.Code >= 300 {
+			t.Errorf("Expected %v to add an entry, got status %d: %s", test.target, w.Code, w.Body.String())
+			continue
+		}
+		if test.list != "" && !listed(n) {
+			t.Errorf("Expected %v to list the new entry", test.list)
+		}
+		expectStatus(t, do(s, http.MethodDelete, test.target, ""), http.StatusBadRequest)
+		expectStatus(t, do(s, http.MethodDelete, test.target+"?"+test.remove, ""), http.StatusNoContent)
+		if test.list != "" && listed(n) {
+			t.Errorf("Expected %v to no longer list the removed entry", test.list)
+		}
+		expectStatus(t, do(s, http.MethodDelete, test.target+"?"+test.remove, ""), test.missing)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	i, s, _ := newTestAPI(t)
+	w := do(s, http.MethodPut, "/keys", `{"address": "192.0.2.1:1234", "key": "FMb88lBCpYFqrEzsigPNVA=="}`)
+	expectStatus(t, w, http.StatusOK)
+	var res keyResponse
+	decode(t, w, &res)
+	if res.ID != "192.0.2.1:1234" || res.Expires.IsZero() {
+		t.Errorf("Expected the key to be stored by address with the default TTL, got %+v", res)
+	}
+	if n := i.Keys.Len(); n != 1 {
+		t.Errorf("Expected 1 key, got %d", n)
+	}
+	expectStatus(t, do(s, http.MethodPut, "/keys", `{"personaId": 1, "key": "not a key"}`), http.StatusBadRequest)
+	expectStatus(t, do(s, http.MethodPut, "/keys", `{"key": "FMb88lBCpYFqrEzsigPNVA=="}`), http.StatusBadRequest)
+}
@@ -0,0 +1,71 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/WorldUnitedNFS/freeroam"
+)
+
+type banRequest struct {
+	PersonaID int    `json:"personaId"`
+	Network   string `json:"network"`
+	Reason    string `json:"reason"`
+	// Duration is the length of the ban in seconds; 0 bans permanently.
+	Duration int `json:"duration"`
+}
+
+// handleBans lists bans on GET, adds a ban on POST and lifts the ban given by the "id"
+// query parameter on DELETE. A ban targets either a persona or an IP address or CIDR range.
+func (s *Server) handleBans(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.i.Bans.List())
+	case http.MethodPost:
+		var req banRequest
+		if err := readJSON(w, r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		ban := freeroam.Ban{
+			PersonaID: req.PersonaID,
+			Network:   req.Network,
+			Reason:    req.Reason,
+		}
+		if req.Duration > 0 {
+			ban.Expires = time.Now().Add(time.Duration(req.Duration) * time.Second)
+		}
+		ban, err := s.i.Ban(ban)
+		if err != nil {
+			status := http.StatusInternalServerError
+			if ban.ID == "" {
+				status = http.StatusBadRequest
+			}
+			writeError(w, status, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, ban)
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			writeError(w, http.StatusBadRequest, "id is required")
+			return
+		}
+		removed, err := s.i.Unban(id)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		if !removed {
+			writeError(w, http.StatusNotFound, "no such ban")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPost, http.MethodDelete)
+	}
+}
@@ -0,0 +1,32 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/WorldUnitedNFS/freeroam"
+)
+
+func TestBans(t *testing.T) {
+	i, s, addr := newTestAPI(t)
+	connectTestClient(t, i, addr, 7)
+
+	expectStatus(t, do(s, http.MethodPost, "/bans", `{"reason": "no target"}`), http.StatusBadRequest)
+	expectStatus(t, do(s, http.MethodPost, "/bans", `{"network": "not an ip"}`), http.StatusBadRequest)
+	expectStatus(t, do(s, http.MethodPatch, "/bans", ""), http.StatusMethodNotAllowed)
+
+	w := do(s, http.MethodPost, "/bans", `{"personaId": 7, "reason": "cheating", "duration": 60}`)
+	expectStatus(t, w, http.StatusOK)
+	var ban freeroam.Ban
+	decode(t, w, &ban)
+	if ban.ID != "persona:7" || ban.Expires.IsZero() {
+		t.Errorf("Expected a temporary persona ban, got %+v", ban)
+	}
+	if count := i.ClientCount(); count != 0 {
+		t.Errorf("Expected the banned client to be kicked, got %d clients", count)
+	}
+}
@@ -0,0 +1,73 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+)
+
+type blockRequest struct {
+	PersonaID int   `json:"personaId"`
+	BlockedID int   `json:"blockedId"`
+	Blocked   []int `json:"blocked"`
+}
+
+type blockResponse struct {
+	PersonaID int   `json:"personaId"`
+	Blocked   []int `json:"blocked"`
+}
+
+// handleBlocks manages the personas blocked by a persona. GET returns them, PUT replaces
+// them with the "blocked" list, POST adds "blockedId" and DELETE removes the "blockedId"
+// query parameter. Blocked players and the players blocking them don't see each other.
+func (s *Server) handleBlocks(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		personaID, err := strconv.Atoi(r.URL.Query().Get("personaId"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "personaId is required")
+			return
+		}
+		writeJSON(w, http.StatusOK, blockResponse{PersonaID: personaID, Blocked: s.i.Blocks(personaID)})
+	case http.MethodPut, http.MethodPost:
+		var req blockRequest
+		if err := readJSON(w, r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.PersonaID == 0 {
+			writeError(w, http.StatusBadRequest, "personaId is required")
+			return
+		}
+		if r.Method == http.MethodPut {
+			s.i.SetBlocks(req.PersonaID, req.Blocked)
+		} else if req.BlockedID != 0 {
+			s.i.Block(req.PersonaID, req.BlockedID)
+		} else {
+			writeError(w, http.StatusBadRequest, "blockedId is required")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		personaID, err := strconv.Atoi(r.URL.Query().Get("personaId"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "personaId is required")
+			return
+		}
+		blockedID, err := strconv.Atoi(r.URL.Query().Get("blockedId"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "blockedId is required")
+			return
+		}
+		if !s.i.Unblock(personaID, blockedID) {
+			writeError(w, http.StatusNotFound, "no such block")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete)
+	}
+}
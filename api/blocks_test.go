@@ -0,0 +1,26 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestBlocks(t *testing.T) {
+	i, s, _ := newTestAPI(t)
+
+	expectStatus(t, do(s, http.MethodPut, "/blocks", `{"blocked": [2]}`), http.StatusBadRequest)
+	expectStatus(t, do(s, http.MethodPost, "/blocks", `{"personaId": 1}`), http.StatusBadRequest)
+	expectStatus(t, do(s, http.MethodGet, "/blocks", ""), http.StatusBadRequest)
+	expectStatus(t, do(s, http.MethodDelete, "/blocks?personaId=1", ""), http.StatusBadRequest)
+
+	expectStatus(t, do(s, http.MethodPut, "/blocks", `{"personaId": 1, "blocked": [2, 3]}`), http.StatusNoContent)
+	expectStatus(t, do(s, http.MethodPost, "/blocks", `{"personaId": 1, "blockedId": 4}`), http.StatusNoContent)
+	if blocked := i.Blocks(1); !slices.Equal(blocked, []int{2, 3, 4}) {
+		t.Errorf("Expected PUT to replace the blocks and POST to add to them, got %v", blocked)
+	}
+}
@@ -0,0 +1,45 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/WorldUnitedNFS/freeroam"
+)
+
+// handleGroups lists parties and crews on GET, registers or replaces a group on PUT/POST
+// and removes the group given by the "id" query parameter on DELETE.
+func (s *Server) handleGroups(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.i.Groups())
+	case http.MethodPut, http.MethodPost:
+		var req freeroam.Group
+		if err := readJSON(w, r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.ID == "" {
+			writeError(w, http.StatusBadRequest, "id is required")
+			return
+		}
+		s.i.SetGroup(req)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if id == "" {
+			writeError(w, http.StatusBadRequest, "id is required")
+			return
+		}
+		if !s.i.RemoveGroup(id) {
+			writeError(w, http.StatusNotFound, "no such group")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete)
+	}
+}
@@ -0,0 +1,21 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestGroups(t *testing.T) {
+	i, s, _ := newTestAPI(t)
+
+	expectStatus(t, do(s, http.MethodPut, "/groups", `{"members": [1, 2]}`), http.StatusBadRequest)
+	expectStatus(t, do(s, http.MethodPut, "/groups", `{"id": "party:1", "members": [1, 2]}`), http.StatusNoContent)
+	if groups := i.Groups(); len(groups) != 1 || groups[0].ID != "party:1" || !slices.Equal(groups[0].Members, []int{1, 2}) {
+		t.Errorf("Unexpected groups %+v", groups)
+	}
+}
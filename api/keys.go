@@ -0,0 +1,64 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/WorldUnitedNFS/freeroam"
+	"github.com/WorldUnitedNFS/freeroam/crypto"
+)
+
+type keyRequest struct {
+	Address   string `json:"address"`
+	PersonaID int    `json:"personaId"`
+	Key       string `json:"key"`
+	TTL       int    `json:"ttl"`
+}
+
+type keyResponse struct {
+	ID      string    `json:"id"`
+	Expires time.Time `json:"expires,omitempty"`
+}
+
+// handleKeys registers or rotates a session key on PUT/POST and revokes it on DELETE.
+// Keys are identified by the "address" or "personaId" field or query parameter.
+func (s *Server) handleKeys(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodPut, http.MethodPost:
+		var req keyRequest
+		if err := readJSON(w, r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.Address == "" && req.PersonaID == 0 {
+			writeError(w, http.StatusBadRequest, "address or personaId is required")
+			return
+		}
+		key, err := crypto.ParseSessionKey(req.Key)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		entry := s.i.Keys.Set(req.Address, req.PersonaID, key, s.ttl(req.TTL))
+		writeJSON(w, http.StatusOK, keyResponse{ID: entry.ID, Expires: entry.Expires})
+	case http.MethodDelete:
+		personaID, _ := strconv.Atoi(r.URL.Query().Get("personaId"))
+		address := r.URL.Query().Get("address")
+		if address == "" && personaID == 0 {
+			writeError(w, http.StatusBadRequest, "address or personaId is required")
+			return
+		}
+		if !s.i.RevokeSessionKey(freeroam.SessionKeyID(address, personaID)) {
+			writeError(w, http.StatusNotFound, "no such key")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodPut, http.MethodPost, http.MethodDelete)
+	}
+}
@@ -0,0 +1,70 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/WorldUnitedNFS/freeroam"
+)
+
+type moderationRequest struct {
+	Address   string `json:"address"`
+	PersonaID int    `json:"personaId"`
+	// Duration is how long the action lasts in seconds. For kicks it is the time
+	// before the player may rejoin; 0 lets them rejoin right away, and for hide and
+	// ghost 0 lifts the action.
+	Duration int    `json:"duration"`
+	Reason   string `json:"reason"`
+}
+
+type moderationResponse struct {
+	Clients int `json:"clients"`
+}
+
+// moderationHandler returns a handler that applies action to the clients selected
+// by the "address" or "personaId" field on POST.
+func moderationHandler(action func(req moderationRequest, duration time.Duration) (int, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			methodNotAllowed(w, http.MethodPost)
+			return
+		}
+		var req moderationRequest
+		if err := readJSON(w, r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		count, err := action(req, time.Duration(req.Duration)*time.Second)
+		switch {
+		case errors.Is(err, freeroam.ErrNoTarget), errors.Is(err, freeroam.ErrNoPersona):
+			writeError(w, http.StatusBadRequest, err.Error())
+		case err != nil:
+			writeError(w, http.StatusInternalServerError, err.Error())
+		case count == 0:
+			writeError(w, http.StatusNotFound, "no such client")
+		default:
+			writeJSON(w, http.StatusOK, moderationResponse{Clients: count})
+		}
+	}
+}
+
+func (s *Server) handleKick(req moderationRequest, duration time.Duration) (int, error) {
+	reason := req.Reason
+	if reason == "" {
+		reason = "kicked by admin"
+	}
+	return s.i.Kick(req.Address, req.PersonaID, duration, reason)
+}
+
+func (s *Server) handleHide(req moderationRequest, duration time.Duration) (int, error) {
+	return s.i.Hide(req.Address, req.PersonaID, duration)
+}
+
+func (s *Server) handleGhost(req moderationRequest, duration time.Duration) (int, error) {
+	return s.i.Ghost(req.Address, req.PersonaID, duration)
+}
@@ -0,0 +1,60 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/WorldUnitedNFS/freeroam"
+)
+
+// waitForPlayers polls the player list until fn returns true for it.
+func waitForPlayers(t *testing.T, i *freeroam.Server, fn func([]*freeroam.PlayerState) bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !fn(i.Players()) {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for the player list")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestModeration(t *testing.T) {
+	i, s, addr := newTestAPI(t)
+	conn := connectTestClient(t, i, addr, 7)
+
+	expectStatus(t, do(s, http.MethodPost, "/hide", `{}`), http.StatusBadRequest)
+	expectStatus(t, do(s, http.MethodPost, "/hide", `{"personaId": 99, "duration": 60}`), http.StatusNotFound)
+	expectStatus(t, do(s, http.MethodGet, "/hide", ""), http.StatusMethodNotAllowed)
+
+	w := do(s, http.MethodPost, "/hide", `{"address": "`+conn.LocalAddr().String()+`", "duration": 60}`)
+	expectStatus(t, w, http.StatusOK)
+	var res moderationResponse
+	decode(t, w, &res)
+	if res.Clients != 1 {
+		t.Errorf("Expected the client to be hidden by address, got %d clients", res.Clients)
+	}
+
+	expectStatus(t, do(s, http.MethodPost, "/ghost", `{"personaId": 7, "duration": 60}`), http.StatusOK)
+	waitForPlayers(t, i, func(players []*freeroam.PlayerState) bool {
+		return len(players) == 0
+	})
+	expectStatus(t, do(s, http.MethodPost, "/ghost", `{"personaId": 7}`), http.StatusOK)
+	waitForPlayers(t, i, func(players []*freeroam.PlayerState) bool {
+		return len(players) == 1
+	})
+
+	expectStatus(t, do(s, http.MethodPost, "/kick", `{"personaId": 7, "duration": 60, "reason": "testing"}`), http.StatusOK)
+	if count := i.ClientCount(); count != 0 {
+		t.Errorf("Expected the kicked client to be removed, got %d clients", count)
+	}
+	if ban, ok := i.Bans.CheckPersona(7); !ok || ban.Reason != "testing" {
+		t.Errorf("Expected the kicked persona to be banned temporarily, got %+v", ban)
+	}
+	expectStatus(t, do(s, http.MethodPost, "/kick", `{"personaId": 7}`), http.StatusNotFound)
+}
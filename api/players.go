@@ -0,0 +1,47 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+
+	"github.com/WorldUnitedNFS/freeroam"
+)
+
+type playerResponse struct {
+	Address     string            `json:"address"`
+	PersonaID   int               `json:"personaId"`
+	PersonaName string            `json:"personaName"`
+	Channel     string            `json:"channel"`
+	Zone        string            `json:"zone,omitempty"`
+	Instance    string            `json:"instance,omitempty"`
+	X           float64           `json:"x"`
+	Y           float64           `json:"y"`
+	Net         freeroam.NetStats `json:"net"`
+}
+
+// handlePlayers lists the ready players as of the last server tick.
+func (s *Server) handlePlayers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, http.MethodGet)
+		return
+	}
+	players := s.i.Players()
+	res := make([]playerResponse, 0, len(players))
+	for _, p := range players {
+		res = append(res, playerResponse{
+			Address:     p.Client.Addr.String(),
+			PersonaID:   p.Player.PersonaID,
+			PersonaName: p.Player.PersonaName,
+			Channel:     p.ChannelName,
+			Zone:        p.Zone,
+			Instance:    p.Instance,
+			X:           p.Pos.X,
+			Y:           p.Pos.Y,
+			Net:         p.Net,
+		})
+	}
+	writeJSON(w, http.StatusOK, res)
+}
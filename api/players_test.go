@@ -0,0 +1,28 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestPlayers(t *testing.T) {
+	i, s, addr := newTestAPI(t)
+	conn := connectTestClient(t, i, addr, 7)
+
+	w := do(s, http.MethodGet, "/players", "")
+	expectStatus(t, w, http.StatusOK)
+	var res []playerResponse
+	decode(t, w, &res)
+	if len(res) != 1 {
+		t.Fatalf("Expected 1 player, got %d", len(res))
+	}
+	p := res[0]
+	if p.Address != conn.LocalAddr().String() || p.PersonaID != 7 || p.PersonaName != "player" || p.Channel != "channel" {
+		t.Errorf("Unexpected player %+v", p)
+	}
+	expectStatus(t, do(s, http.MethodPost, "/players", ""), http.StatusMethodNotAllowed)
+}
@@ -0,0 +1,50 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/WorldUnitedNFS/freeroam"
+)
+
+// handleSlots lists per-persona slot capacities on GET, sets the capacity of a persona on
+// PUT/POST and restores the server-wide capacity of the persona given by the "personaId"
+// query parameter on DELETE.
+func (s *Server) handleSlots(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.i.SlotCapacities())
+	case http.MethodPut, http.MethodPost:
+		var req freeroam.SlotCapacity
+		if err := readJSON(w, r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.PersonaID == 0 {
+			writeError(w, http.StatusBadRequest, "personaId is required")
+			return
+		}
+		if err := s.i.SetSlotCapacity(req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		personaID, err := strconv.Atoi(r.URL.Query().Get("personaId"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "personaId is required")
+			return
+		}
+		if !s.i.RemoveSlotCapacity(personaID) {
+			writeError(w, http.StatusNotFound, "no such slot capacity")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete)
+	}
+}
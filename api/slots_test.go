@@ -0,0 +1,24 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/WorldUnitedNFS/freeroam"
+)
+
+func TestSlots(t *testing.T) {
+	i, s, _ := newTestAPI(t)
+
+	expectStatus(t, do(s, http.MethodPut, "/slots", `{"maxVisiblePlayers": 4}`), http.StatusBadRequest)
+	expectStatus(t, do(s, http.MethodPut, "/slots", `{"personaId": 7, "maxVisiblePlayers": 0}`), http.StatusBadRequest)
+	expectStatus(t, do(s, http.MethodPut, "/slots", `{"personaId": 7, "maxVisiblePlayers": 4}`), http.StatusNoContent)
+	want := freeroam.SlotCapacity{PersonaID: 7, MaxVisiblePlayers: 4}
+	if capacities := i.SlotCapacities(); len(capacities) != 1 || capacities[0] != want {
+		t.Errorf("Unexpected slot capacities %+v", capacities)
+	}
+}
@@ -0,0 +1,46 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/WorldUnitedNFS/freeroam"
+)
+
+// handleSpectators lists spectators on GET, gives a persona the spectator role on PUT/POST
+// and takes it away from the persona given by the "personaId" query parameter on DELETE.
+func (s *Server) handleSpectators(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, http.StatusOK, s.i.Spectators())
+	case http.MethodPut, http.MethodPost:
+		var req freeroam.Spectator
+		if err := readJSON(w, r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if req.PersonaID == 0 {
+			writeError(w, http.StatusBadRequest, "personaId is required")
+			return
+		}
+		s.i.SetSpectator(req)
+		w.WriteHeader(http.StatusNoContent)
+	case http.MethodDelete:
+		personaID, err := strconv.Atoi(r.URL.Query().Get("personaId"))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "personaId is required")
+			return
+		}
+		if !s.i.RemoveSpectator(personaID) {
+			writeError(w, http.StatusNotFound, "no such spectator")
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		methodNotAllowed(w, http.MethodGet, http.MethodPut, http.MethodPost, http.MethodDelete)
+	}
+}
@@ -0,0 +1,22 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package api
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/WorldUnitedNFS/freeroam"
+)
+
+func TestSpectators(t *testing.T) {
+	i, s, _ := newTestAPI(t)
+
+	expectStatus(t, do(s, http.MethodPut, "/spectators", `{"ignoreRadius": true}`), http.StatusBadRequest)
+	expectStatus(t, do(s, http.MethodPut, "/spectators", `{"personaId": 7, "follow": 3}`), http.StatusNoContent)
+	if spectators := i.Spectators(); len(spectators) != 1 || spectators[0] != (freeroam.Spectator{PersonaID: 7, Follow: 3}) {
+		t.Errorf("Unexpected spectators %+v", spectators)
+	}
+}
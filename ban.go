@@ -0,0 +1,268 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrInvalidBan = errors.New("ban needs either a persona ID or an IP address or CIDR range")
+
+// Ban keeps a persona, an IP address or a CIDR range off the server.
+type Ban struct {
+	// ID is "persona:<id>" for persona bans, otherwise the banned IP or CIDR range.
+	ID        string    `json:"id"`
+	PersonaID int       `json:"personaId,omitempty"`
+	Network   string    `json:"network,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	Created   time.Time `json:"created"`
+	// Expires is zero for permanent bans.
+	Expires time.Time `json:"expires,omitempty"`
+
+	ipNet *net.IPNet
+}
+
+func (b Ban) expired(now time.Time) bool {
+	return !b.Expires.IsZero() && now.After(b.Expires)
+}
+
+// normalize validates the ban target and fills in its ID.
+func (b *Ban) normalize() error {
+	if (b.PersonaID == 0) == (b.Network == "") {
+		return ErrInvalidBan
+	}
+	if b.PersonaID != 0 {
+		b.ID = "persona:" + strconv.Itoa(b.PersonaID)
+		return nil
+	}
+	network := b.Network
+	if !strings.Contains(network, "/") {
+		ip := net.ParseIP(network)
+		if ip == nil {
+			return ErrInvalidIP
+		}
+		if ip.To4() != nil {
+			network = ip.String() + "/32"
+		} else {
+			network = ip.String() + "/128"
+		}
+	}
+	_, ipNet, err := net.ParseCIDR(network)
+	if err != nil {
+		return err
+	}
+	b.ipNet = ipNet
+	b.Network = ipNet.String()
+	if ones, bits := ipNet.Mask.Size(); ones == bits {
+		b.Network = ipNet.IP.String()
+	}
+	b.ID = b.Network
+	return nil
+}
+
+// BanList holds the active bans. If it has a file, changes are written to it and
+// changes made to the file by others are picked up by Reload.
+type BanList struct {
+	sync.RWMutex
+	path    string
+	modTime time.Time
+	bans    map[string]Ban
+}
+
+// NewBanList creates a ban list backed by the file at path, which doesn't have to exist yet.
+// An empty path keeps the bans in memory only.
+func NewBanList(path string) (*BanList, error) {
+	l := &BanList{
+		path: path,
+		bans: make(map[string]Ban),
+	}
+	_, err := l.Reload()
+	return l, err
+}
+
+// Reload reads the ban file if it changed since it was last read or written.
+// It returns true if the bans were replaced. If the file is invalid, the current bans
+// are kept and the file isn't read again until it changes.
+func (l *BanList) Reload() (bool, error) {
+	if l.path == "" {
+		return false, nil
+	}
+	info, err := os.Stat(l.path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	l.RLock()
+	unchanged := info.ModTime().Equal(l.modTime)
+	l.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return false, err
+	}
+	bans, err := parseBans(data)
+	l.Lock()
+	defer l.Unlock()
+	l.modTime = info.ModTime()
+	if err != nil {
+		return false, err
+	}
+	l.bans = bans
+	return true, nil
+}
+
+func parseBans(data []byte) (map[string]Ban, error) {
+	var list []Ban
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, err
+	}
+	bans := make(map[string]Ban, len(list))
+	for _, ban := range list {
+		if err := ban.normalize(); err != nil {
+			return nil, err
+		}
+		bans[ban.ID] = ban
+	}
+	return bans, nil
+}
+
+// save drops expired bans and writes the rest to the ban file. It must be called with the lock held.
+func (l *BanList) save() error {
+	now := time.Now()
+	for id, ban := range l.bans {
+		if ban.expired(now) {
+			delete(l.bans, id)
+		}
+	}
+	if l.path == "" {
+		return nil
+	}
+	data, err := json.MarshalIndent(l.sorted(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(l.path), filepath.Base(l.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), l.path); err != nil {
+		return err
+	}
+	info, err := os.Stat(l.path)
+	if err != nil {
+		return err
+	}
+	l.modTime = info.ModTime()
+	return nil
+}
+
+// Add bans the persona or network of ban, replacing any ban with the same target.
+func (l *BanList) Add(ban Ban) (Ban, error) {
+	if err := ban.normalize(); err != nil {
+		return ban, err
+	}
+	if ban.Created.IsZero() {
+		ban.Created = time.Now()
+	}
+	l.Lock()
+	defer l.Unlock()
+	l.bans[ban.ID] = ban
+	return ban, l.save()
+}
+
+// Remove lifts the ban with the given ID. It returns false if there was no such ban.
+func (l *BanList) Remove(id string) (bool, error) {
+	l.Lock()
+	defer l.Unlock()
+	if _, ok := l.bans[id]; !ok {
+		return false, nil
+	}
+	delete(l.bans, id)
+	return true, l.save()
+}
+
+// List returns all bans that haven't expired, ordered by ID.
+func (l *BanList) List() []Ban {
+	now := time.Now()
+	l.RLock()
+	defer l.RUnlock()
+	return slices.DeleteFunc(l.sorted(), func(ban Ban) bool {
+		return ban.expired(now)
+	})
+}
+
+func (l *BanList) sorted() []Ban {
+	list := make([]Ban, 0, len(l.bans))
+	for _, ban := range l.bans {
+		list = append(list, ban)
+	}
+	slices.SortFunc(list, func(a, b Ban) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return list
+}
+
+// CheckIP returns the ban covering ip, if any. A nil ban list bans nobody.
+func (l *BanList) CheckIP(ip net.IP) (Ban, bool) {
+	if l == nil {
+		return Ban{}, false
+	}
+	now := time.Now()
+	l.RLock()
+	defer l.RUnlock()
+	for _, ban := range l.bans {
+		if ban.ipNet != nil && ban.ipNet.Contains(ip) && !ban.expired(now) {
+			return ban, true
+		}
+	}
+	return Ban{}, false
+}
+
+// CheckPersona returns the ban of personaID, if any. A nil ban list bans nobody.
+func (l *BanList) CheckPersona(personaID int) (Ban, bool) {
+	if l == nil {
+		return Ban{}, false
+	}
+	l.RLock()
+	defer l.RUnlock()
+	ban, ok := l.bans["persona:"+strconv.Itoa(personaID)]
+	if !ok || ban.expired(time.Now()) {
+		return Ban{}, false
+	}
+	return ban, true
+}
+
+// Check returns the ban that applies to a client at ip using personaID, if any.
+// A personaID of 0 only checks the IP.
+func (l *BanList) Check(ip net.IP, personaID int) (Ban, bool) {
+	if personaID != 0 {
+		if ban, ok := l.CheckPersona(personaID); ok {
+			return ban, true
+		}
+	}
+	return l.CheckIP(ip)
+}
@@ -0,0 +1,151 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"math/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBanList(t *testing.T) {
+	l, err := NewBanList("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Add(Ban{}); err != ErrInvalidBan {
+		t.Errorf("Expected ErrInvalidBan, got %v", err)
+	}
+	ban, err := l.Add(Ban{Network: "10.1.2.3/16", Reason: "spam"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ban.ID != "10.1.0.0/16" {
+		t.Errorf("Expected CIDR range to be normalized, got %q", ban.ID)
+	}
+	if _, ok := l.CheckIP(net.IPv4(10, 1, 200, 1)); !ok {
+		t.Error("Expected IP in banned range to be banned")
+	}
+	if _, ok := l.CheckIP(net.IPv4(10, 2, 0, 1)); ok {
+		t.Error("Expected IP outside banned range to not be banned")
+	}
+
+	l.Add(Ban{PersonaID: 7, Expires: time.Now().Add(-time.Second)})
+	if _, ok := l.CheckPersona(7); ok {
+		t.Error("Expected expired ban to be ignored")
+	}
+	l.Add(Ban{PersonaID: 7, Expires: time.Now().Add(time.Hour)})
+	if ban, ok := l.Check(net.IPv4(192, 0, 2, 1), 7); !ok || ban.ID != "persona:7" {
+		t.Errorf("Expected persona ban, got %+v", ban)
+	}
+	if removed, _ := l.Remove("persona:7"); !removed {
+		t.Error("Expected persona ban to be removed")
+	}
+	if bans := l.List(); len(bans) != 1 {
+		t.Errorf("Expected 1 ban, got %d", len(bans))
+	}
+}
+
+func TestBanListReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	l, err := NewBanList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Add(Ban{Network: "192.0.2.1", Reason: "cheating"}); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := l.Reload(); reloaded || err != nil {
+		t.Errorf("Expected own changes to not trigger a reload, got %v, %v", reloaded, err)
+	}
+
+	other, err := NewBanList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ban, ok := other.CheckIP(net.IPv4(192, 0, 2, 1)); !ok || ban.Reason != "cheating" {
+		t.Errorf("Expected ban to be loaded from file, got %+v", ban)
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"personaId": 3}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	if reloaded, err := l.Reload(); !reloaded || err != nil {
+		t.Fatalf("Expected changed file to be reloaded, got %v, %v", reloaded, err)
+	}
+	if _, ok := l.CheckIP(net.IPv4(192, 0, 2, 1)); ok {
+		t.Error("Expected IP ban to be gone after reload")
+	}
+	if _, ok := l.CheckPersona(3); !ok {
+		t.Error("Expected persona ban to be loaded after reload")
+	}
+
+	if err := os.WriteFile(path, []byte(`[{"personaId": 4}, {"network": "bad"}]`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	later = later.Add(time.Minute)
+	os.Chtimes(path, later, later)
+	if reloaded, err := l.Reload(); reloaded || err == nil {
+		t.Errorf("Expected invalid file to fail to load, got %v, %v", reloaded, err)
+	}
+	if reloaded, err := l.Reload(); reloaded || err != nil {
+		t.Errorf("Expected invalid file to not be read again until it changes, got %v, %v", reloaded, err)
+	}
+	if _, ok := l.CheckPersona(3); !ok {
+		t.Error("Expected previous bans to be kept when the file is invalid")
+	}
+}
+
+func TestBanListDropsExpired(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bans.json")
+	l, err := NewBanList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Add(Ban{PersonaID: 1, Expires: time.Now().Add(-time.Minute)}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.Add(Ban{PersonaID: 2}); err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewBanList(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bans := other.sorted(); len(bans) != 1 || bans[0].PersonaID != 2 {
+		t.Errorf("Expected expired bans to be dropped from the file, got %+v", bans)
+	}
+}
+
+func TestBanKicksClients(t *testing.T) {
+	config := DefaultConfig()
+	config.UDP.Workers = 2
+	config.Bans.File = ""
+	i := newTestServerWithConfig(t, config)
+	populate(i, 5, rand.New(rand.NewSource(1)))
+
+	if _, err := i.Ban(Ban{PersonaID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if count := i.ClientCount(); count != 4 {
+		t.Errorf("Expected banned persona to be kicked, got %d clients", count)
+	}
+	if _, err := i.Ban(Ban{Network: "127.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	if count := i.ClientCount(); count != 0 {
+		t.Errorf("Expected banned network to be kicked, got %d clients", count)
+	}
+	i.inject(testAddr(0), testHello(0))
+	i.wait()
+	if count := i.ClientCount(); count != 0 {
+		t.Errorf("Expected handshake from banned network to be rejected, got %d clients", count)
+	}
+}
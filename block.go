@@ -0,0 +1,140 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import "sync"
+
+// blockList holds the block relationships registered by the core server.
+// Players never see each other if either of them blocked the other.
+type blockList struct {
+	sync.RWMutex
+	// blocks maps each persona to the personas it blocked, and blockedBy is its reverse.
+	blocks    map[int]map[int]struct{}
+	blockedBy map[int]map[int]struct{}
+	// hidden maps each persona to the personas it must not see. The sets are replaced
+	// instead of modified, so that they can be used without holding the lock.
+	hidden map[int]map[int]struct{}
+}
+
+func newBlockList() *blockList {
+	return &blockList{
+		blocks:    make(map[int]map[int]struct{}),
+		blockedBy: make(map[int]map[int]struct{}),
+		hidden:    make(map[int]map[int]struct{}),
+	}
+}
+
+func addToSet(sets map[int]map[int]struct{}, key, value int) {
+	if sets[key] == nil {
+		sets[key] = make(map[int]struct{})
+	}
+	sets[key][value] = struct{}{}
+}
+
+func removeFromSet(sets map[int]map[int]struct{}, key, value int) {
+	delete(sets[key], value)
+	if len(sets[key]) == 0 {
+		delete(sets, key)
+	}
+}
+
+// set replaces the personas blocked by personaID.
+func (l *blockList) set(personaID int, blocked []int) {
+	l.Lock()
+	defer l.Unlock()
+	affected := []int{personaID}
+	for id := range l.blocks[personaID] {
+		removeFromSet(l.blockedBy, id, personaID)
+		affected = append(affected, id)
+	}
+	delete(l.blocks, personaID)
+	for _, id := range blocked {
+		if id == personaID {
+			continue
+		}
+		addToSet(l.blocks, personaID, id)
+		addToSet(l.blockedBy, id, personaID)
+		affected = append(affected, id)
+	}
+	l.update(affected...)
+}
+
+// add records that personaID blocked blockedID.
+func (l *blockList) add(personaID, blockedID int) {
+	if personaID == blockedID {
+		return
+	}
+	l.Lock()
+	defer l.Unlock()
+	addToSet(l.blocks, personaID, blockedID)
+	addToSet(l.blockedBy, blockedID, personaID)
+	l.update(personaID, blockedID)
+}
+
+// remove lifts the block of blockedID by personaID. It returns false if there was no such block.
+func (l *blockList) remove(personaID, blockedID int) bool {
+	l.Lock()
+	defer l.Unlock()
+	if _, ok := l.blocks[personaID][blockedID]; !ok {
+		return false
+	}
+	removeFromSet(l.blocks, personaID, blockedID)
+	removeFromSet(l.blockedBy, blockedID, personaID)
+	l.update(personaID, blockedID)
+	return true
+}
+
+// update rebuilds the hidden sets of the given personas. It must be called with the lock held.
+func (l *blockList) update(personaIDs ...int) {
+	for _, id := range personaIDs {
+		hidden := make(map[int]struct{}, len(l.blocks[id])+len(l.blockedBy[id]))
+		for other := range l.blocks[id] {
+			hidden[other] = struct{}{}
+		}
+		for other := range l.blockedBy[id] {
+			hidden[other] = struct{}{}
+		}
+		if len(hidden) == 0 {
+			delete(l.hidden, id)
+		} else {
+			l.hidden[id] = hidden
+		}
+	}
+}
+
+// blocked returns the personas blocked by personaID, ordered by ID.
+func (l *blockList) blocked(personaID int) []int {
+	l.RLock()
+	defer l.RUnlock()
+	return sortedIDs(l.blocks[personaID])
+}
+
+// hiddenFrom returns the personas that personaID must not see. The result must not be modified.
+func (l *blockList) hiddenFrom(personaID int) map[int]struct{} {
+	l.RLock()
+	defer l.RUnlock()
+	return l.hidden[personaID]
+}
+
+// SetBlocks replaces the list of personas blocked by personaID.
+// The change takes effect on the next slot recalculation.
+func (i *Server) SetBlocks(personaID int, blocked []int) {
+	i.blocks.set(personaID, blocked)
+}
+
+// Block records that personaID blocked blockedID, so that neither sees the other.
+func (i *Server) Block(personaID, blockedID int) {
+	i.blocks.add(personaID, blockedID)
+}
+
+// Unblock lifts the block of blockedID by personaID. It returns false if there was no such block.
+func (i *Server) Unblock(personaID, blockedID int) bool {
+	return i.blocks.remove(personaID, blockedID)
+}
+
+// Blocks returns the personas blocked by personaID.
+func (i *Server) Blocks(personaID int) []int {
+	return i.blocks.blocked(personaID)
+}
@@ -0,0 +1,59 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestBlockList(t *testing.T) {
+	l := newBlockList()
+	l.add(1, 2)
+	l.add(2, 1)
+	l.add(1, 3)
+	if _, ok := l.hiddenFrom(3)[1]; !ok {
+		t.Error("Expected blocks to hide players from each other in both directions")
+	}
+	l.remove(1, 2)
+	if _, ok := l.hiddenFrom(1)[2]; !ok {
+		t.Error("Expected the other direction of a mutual block to remain")
+	}
+	l.set(2, nil)
+	if _, ok := l.hiddenFrom(1)[2]; ok {
+		t.Error("Expected replaced block list to lift the block")
+	}
+	l.set(1, []int{4})
+	if _, ok := l.hiddenFrom(3)[1]; ok {
+		t.Error("Expected replaced block list to lift the old blocks")
+	}
+	if blocked := l.blocked(1); len(blocked) != 1 || blocked[0] != 4 {
+		t.Errorf("Expected persona 1 to block only persona 4, got %v", blocked)
+	}
+}
+
+func TestBlocksAffectSlots(t *testing.T) {
+	config := DefaultConfig()
+	config.UDP.Workers = 2
+	config.UDP.DisableRadiusSync = true
+	i := newTestServerWithConfig(t, config)
+	populate(i, 3, rand.New(rand.NewSource(1)))
+	first, second := testClient(i, 0), testClient(i, 1)
+
+	i.Block(1, 2)
+	if visible := visibleTo(i, 0); visible[second] {
+		t.Error("Expected blocked player to be removed from the blocker's slots")
+	}
+	if visible := visibleTo(i, 1); visible[first] {
+		t.Error("Expected blocker to be removed from the blocked player's slots")
+	}
+	if visible := visibleTo(i, 2); !visible[first] || !visible[second] {
+		t.Error("Expected blocks to not affect other players")
+	}
+	i.Unblock(1, 2)
+	if visible := visibleTo(i, 0); !visible[second] {
+		t.Error("Expected unblocked player to be visible again")
+	}
+}
@@ -0,0 +1,82 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Layout of the 0x00 channel info subpacket: a byte of unknown meaning, the social
+// filtering flag and a zero-padded channel name that fills the rest of the subpacket.
+const (
+	chanInfoFilterOffset = 1
+	chanInfoNameOffset   = 2
+)
+
+var (
+	ErrChannelInfoTooShort = errors.New("channel info too short")
+	ErrInvalidChannelName  = errors.New("invalid channel name")
+)
+
+// ChannelInfo is the content of a 0x00 channel info subpacket.
+type ChannelInfo struct {
+	// Flags is the first byte of the subpacket; its meaning is unknown.
+	Flags byte
+	// SocialFiltering is set if the player prefers to see players from the same channel.
+	SocialFiltering bool
+	ChannelName     string
+	// NameSize is the size of the zero-padded channel name field. If it is smaller than
+	// the name, the field is made just large enough for the name and a terminator.
+	NameSize int
+}
+
+// DecodeChannelInfo decodes a channel info subpacket.
+func DecodeChannelInfo(data []byte) (ChannelInfo, error) {
+	var info ChannelInfo
+	if len(data) < chanInfoNameOffset {
+		return info, fmt.Errorf("%w (%d bytes)", ErrChannelInfoTooShort, len(data))
+	}
+	info.Flags = data[0]
+	info.SocialFiltering = data[chanInfoFilterOffset] == 1
+	name := data[chanInfoNameOffset:]
+	info.ChannelName = string(name[:cStrLen(name)])
+	info.NameSize = len(name)
+	return info, nil
+}
+
+// Validate checks that the channel info can be encoded.
+func (c ChannelInfo) Validate() error {
+	for i := 0; i < len(c.ChannelName); i++ {
+		if ch := c.ChannelName[i]; ch < 0x20 || ch > 0x7e {
+			return ErrInvalidChannelName
+		}
+	}
+	if size := chanInfoNameOffset + c.nameSize(); size > 255 {
+		return fmt.Errorf("channel info too long (%d bytes)", size)
+	}
+	return nil
+}
+
+func (c ChannelInfo) nameSize() int {
+	if c.NameSize >= len(c.ChannelName) {
+		return c.NameSize
+	}
+	return len(c.ChannelName) + 1
+}
+
+// Encode returns the channel info as subpacket data.
+func (c ChannelInfo) Encode() ([]byte, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	data := make([]byte, chanInfoNameOffset+c.nameSize())
+	data[0] = c.Flags
+	if c.SocialFiltering {
+		data[chanInfoFilterOffset] = 1
+	}
+	copy(data[chanInfoNameOffset:], c.ChannelName)
+	return data, nil
+}
@@ -0,0 +1,59 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestChannelInfo(t *testing.T) {
+	data := testChanInfo("Palmont")
+	data[chanInfoFilterOffset] = 1
+
+	info, err := DecodeChannelInfo(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ChannelName != "Palmont" || !info.SocialFiltering || info.NameSize != 32 {
+		t.Errorf("Unexpected channel info %+v", info)
+	}
+	encoded, err := info.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encoded, data) {
+		t.Errorf("Expected encoding to round-trip:\n%x\n%x", data, encoded)
+	}
+
+	// A name that fills the whole field has no terminator.
+	full := append([]byte{0, 0}, "Rockport"...)
+	info, err = DecodeChannelInfo(full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded, _ := info.Encode(); info.ChannelName != "Rockport" || !bytes.Equal(encoded, full) {
+		t.Errorf("Expected unterminated name to round-trip, got %q, %x", info.ChannelName, encoded)
+	}
+
+	info.ChannelName = "A much longer channel name"
+	if encoded, _ := info.Encode(); len(encoded) != chanInfoNameOffset+len(info.ChannelName)+1 {
+		t.Errorf("Expected name field to grow to fit the name, got %d bytes", len(encoded))
+	}
+}
+
+func TestChannelInfoValidation(t *testing.T) {
+	if _, err := DecodeChannelInfo([]byte{0}); !errors.Is(err, ErrChannelInfoTooShort) {
+		t.Errorf("Expected ErrChannelInfoTooShort, got %v", err)
+	}
+	if _, err := (ChannelInfo{ChannelName: "bad\nname"}).Encode(); err != ErrInvalidChannelName {
+		t.Errorf("Expected ErrInvalidChannelName, got %v", err)
+	}
+	if _, err := (ChannelInfo{ChannelName: strings.Repeat("a", 254)}).Encode(); err == nil {
+		t.Error("Expected oversized channel info to be rejected")
+	}
+}
@@ -8,18 +8,22 @@ import (
 	"bytes"
 	"cmp"
 	"encoding/binary"
-	"fmt"
+	"log"
 	"github.com/WorldUnitedNFS/freeroam/math"
+	"github.com/WorldUnitedNFS/freeroam/spatial"
 	"net"
-	"runtime/debug"
 	"slices"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type clientPosSortInfo struct {
-	Client *Client
-	Length int
+	Client  *Client
+	Channel string
+	Group   bool
+	Pinned  bool
+	Length  int
 }
 
 type clientPosSort []clientPosSortInfo
@@ -41,12 +45,15 @@ type ClientConfig struct {
 	Addr                 *net.UDPAddr
 	Conn                 *net.UDPConn
 	Buffers              *sync.Pool
-	Clients              map[string]*Client
-	AllowedPersonas      []int
+	Allowlist            *PersonaAllowlist
+	Bans                 *BanList
 	VisibilityRadius     float64
+	ExitRadius           float64
+	MinSlotTimeMs        int
 	MaxVisiblePlayers    int
 	PlayerSpawnDelayMs   int
 	DisableRadiusSync    bool
+	SessionKey           *SessionKeyEntry
 }
 
 func newClient(opts ClientConfig) *Client {
@@ -54,6 +61,10 @@ func newClient(opts ClientConfig) *Client {
 	if spawnDelay <= 0 {
 		spawnDelay = 200
 	}
+	exitRadius := opts.ExitRadius
+	if exitRadius < opts.VisibilityRadius {
+		exitRadius = opts.VisibilityRadius
+	}
 	
 	c := &Client{
 		Addr:                  opts.Addr,
@@ -64,20 +75,22 @@ func newClient(opts ClientConfig) *Client {
 		seq:                   0,
 		slots:                 make([]*slotInfo, opts.MaxVisiblePlayers),
 		LastPacket:            time.Now(),
-		clients:               opts.Clients,
-		allowedPersonas:       opts.AllowedPersonas,
+		allowlist:             opts.Allowlist,
+		bans:                  opts.Bans,
 		buffers:               opts.Buffers,
 		updateID:              1,
 		visibilityRadius:      opts.VisibilityRadius,
+		exitRadius:            exitRadius,
+		minSlotTime:           time.Duration(opts.MinSlotTimeMs) * time.Millisecond,
+		slotCapacity:          opts.MaxVisiblePlayers,
+		defaultSlotCapacity:   opts.MaxVisiblePlayers,
 		pendingPlayerQueue:    make([]*Client, 0),
 		playerSpawnDelayMs:    spawnDelay,
 		disableRadiusSync:     opts.DisableRadiusSync,
-		spawnProcessorRunning: false,
-		spawnProcessorStop:    make(chan bool, 1),
+		sessionKey:            opts.SessionKey,
 	}
-	
-	go c.startSpawnProcessor()
-	
+	c.state.Store(&PlayerState{Client: c})
+
 	return c
 }
 
@@ -92,23 +105,49 @@ type Client struct {
 	chanInfo               []byte
 	playerInfo             []byte
 	slots                  []*slotInfo
+	slotCapacity           int
+	defaultSlotCapacity    int
 	LastPacket             time.Time
 	PersonaName            string
-	allowedPersonas        []int
-	ackMissedCount         int
+	personaID              int
+	persona                PlayerInfo
+	channel                ChannelInfo
+	allowlist              *PersonaAllowlist
+	bans                   *BanList
 	updateID               uint8
 	buffers                *sync.Pool
-	clients                map[string]*Client
+	shard                  *shard
+	state                  atomic.Pointer[PlayerState]
+	removed                atomic.Bool
 	posRecvTD              uint16
 	visibilityRadius       float64
+	exitRadius             float64
+	minSlotTime            time.Duration
 	socialFilteringEnabled bool
 	channelName            string
 	pendingPlayerQueue     []*Client
 	playerSpawnDelayMs     int
 	disableRadiusSync      bool
-	spawnProcessorRunning  bool           // Indique si la goroutine de traitement est en cours
-	spawnProcessorStop     chan bool
+	nextSpawn              time.Time
+	hello                  []byte
+	established            bool
+	anomalies              anomalyTracker
+	net                    netEstimator
+	acks                   ackWindow
+	slotGen                uint32
+	hiddenUntil            time.Time
+	ghostUntil             time.Time
+	spectator              *Spectator
+	zone                   *ZoneConfig
+	instance               string
+	inInstance             bool
+	tickRate               int
+	nextBroadcast          time.Time
 	pendingQueueMutex      sync.Mutex
+	sessionKey             *SessionKeyEntry
+	recvSeqs               seqGuard
+	rejectedPackets        atomic.Uint64
+	malformedPackets       atomic.Uint64
 }
 
 func (c *Client) registerUpdate() {
@@ -123,9 +162,7 @@ func (c *Client) SetPlayerSpawnDelay(delayMs int) {
 		delayMs = 200
 	}
 	c.playerSpawnDelayMs = delayMs
-	
-	c.stopSpawnProcessor()
-	go c.startSpawnProcessor()
+	c.nextSpawn = time.Time{}
 }
 
 func (c *Client) GetPendingPlayersCount() int {
@@ -146,24 +183,116 @@ func (c *Client) SetRadiusSync(enabled bool) {
 	c.disableRadiusSync = !enabled
 }
 
+// IsSecure returns true if the client's traffic is authenticated and encrypted with a session key.
+func (c *Client) IsSecure() bool {
+	return c.sessionKey != nil
+}
+
+// RejectedPackets returns the number of packets from the client that failed verification.
+func (c *Client) RejectedPackets() uint64 {
+	return c.rejectedPackets.Load()
+}
+
+// MalformedPackets returns the number of packets from the client that couldn't be parsed.
+func (c *Client) MalformedPackets() uint64 {
+	return c.malformedPackets.Load()
+}
+
+// openPacket verifies and decrypts an inbound packet if the client is in secure mode.
+// Packets that fail verification are counted and must be dropped.
+func (c *Client) openPacket(packet []byte) bool {
+	if c.sessionKey == nil {
+		return true
+	}
+	if err := openPacket(c.sessionKey.Key, packet); err != nil {
+		c.rejectedPackets.Add(1)
+		return false
+	}
+	seq := binary.BigEndian.Uint16(packet[0:2])
+	if err := c.recvSeqs.check(seq); err != nil {
+		c.rejectedPackets.Add(1)
+		return false
+	}
+	c.recvSeqs.accept(seq)
+	return true
+}
+
+// Cleanup marks the client as removed so that it is no longer put into other clients' slots,
+// and drops its pending players.
 func (c *Client) Cleanup() {
-	c.stopSpawnProcessor()
+	c.removed.Store(true)
+	c.leaveInstance()
+	c.pendingQueueMutex.Lock()
+	c.pendingPlayerQueue = c.pendingPlayerQueue[:0]
+	c.pendingQueueMutex.Unlock()
+}
+
+// detach removes the client from its shard.
+func (c *Client) detach() {
+	c.shard.removeClient(c)
+}
+
+// State returns the last state published by the client.
+func (c *Client) State() *PlayerState {
+	return c.state.Load()
+}
+
+// publish makes the client's current data visible to other clients.
+func (c *Client) publish() {
+	c.state.Store(&PlayerState{
+		Client:      c,
+		PersonaName: c.PersonaName,
+		PersonaID:   c.personaID,
+		Player:      c.persona,
+		Channel:     c.channel,
+		ChannelName: c.channelName,
+		Pos:         c.carPos.Pos(),
+		Coords:      c.carPos.Coordinates(),
+		Rotation:    c.carPos.Rotation(),
+		carPos:      c.carPos.Packet(),
+		chanInfo:    c.chanInfo,
+		playerInfo:  c.playerInfo,
+		posRecvTD:   c.posRecvTD,
+		updateID:    c.updateID,
+		hiddenUntil: c.hiddenUntil,
+		ghostUntil:  c.ghostUntil,
+		spectator:   c.spectator != nil,
+		Zone:        c.zoneName(),
+		Instance:    c.instanceName(),
+		Net:         c.net.stats(),
+	})
 }
 
 func getServerTick() uint16 {
 	return uint16(time.Now().UnixMilli())
 }
 
-func (c Client) getTimeDiff() uint16 {
+func (c *Client) getTimeDiff() uint16 {
 	return uint16(time.Now().Sub(c.startTime).Seconds() * 1000)
 }
 
 func (c *Client) getSeq() uint16 {
 	out := c.seq
 	c.seq++
+	c.net.onSend(out, time.Now())
 	return out
 }
 
+// ChannelInfo returns the channel info last sent by the client.
+func (c *Client) ChannelInfo() ChannelInfo {
+	return c.State().Channel
+}
+
+// PlayerInfo returns the player info last sent by the client.
+func (c *Client) PlayerInfo() PlayerInfo {
+	return c.State().Player
+}
+
+// NetStats returns the connection quality of the client as of its last packet.
+func (c *Client) NetStats() NetStats {
+	return c.State().Net
+}
+
 func (c *Client) replyHandshake() {
 	buf := c.buffers.Get().(*bytes.Buffer)
 	buf.Reset()
@@ -172,88 +301,125 @@ func (c *Client) replyHandshake() {
 	binary.Write(buf, binary.BigEndian, getServerTick())
 	binary.Write(buf, binary.BigEndian, c.tickDiff)
 	buf.Write([]byte{0x49, 0x26, 0x03, 0x01})
+	if c.sessionKey != nil {
+		if err := sealPacket(c.sessionKey.Key, buf.Bytes()); err != nil {
+			log.Printf("Error sealing handshake reply for %v: %v", c.Addr.String(), err)
+			c.buffers.Put(buf)
+			return
+		}
+	}
 	c.SendRawPacket(buf.Bytes())
 	c.buffers.Put(buf)
 }
 
 // Active returns true if the client has communicated with the server lately.
-func (c Client) Active() bool {
+func (c *Client) Active() bool {
 	return time.Now().Sub(c.LastPacket).Seconds() < 5
 }
 
-func (c *Client) processPacket(packet []byte) {
-	defer func() {
-		r := recover()
-		if r != nil {
-			fmt.Printf("Error occured while processing packet (%v):\n", len(packet))
-			fmt.Println(r)
-			debug.PrintStack()
-		}
-	}()
-	c.LastPacket = time.Now()
-	srvCounter := binary.BigEndian.Uint16(packet[8:10])
-	for _, slot := range c.slots {
-		if slot != nil && !slot.UpdateACKed {
-			if srvCounter == slot.PacketSentSeq {
-				slot.UpdateACKed = true
-			} else {
-				slot.ACKMissedCount++
+// processPacket applies a decrypted client packet to the client's state.
+// Malformed packets are rejected as a whole and leave the state untouched. Player info
+// that fails validation is only skipped itself; the rest of the packet is applied and
+// the validation error is returned so that the packet still counts as malformed.
+func (c *Client) processPacket(packet []byte) error {
+	frame, err := ParseFrame(packet)
+	if err != nil {
+		return err
+	}
+	// Decode and validate every subpacket up front so that a bad one doesn't leave a
+	// half-applied packet.
+	var (
+		chanInfo, playerInfo []byte
+		channel              ChannelInfo
+		persona              PlayerInfo
+		carPos               CarPosPacket
+		invalid              error
+	)
+	for _, sp := range frame.Subpackets {
+		switch sp.Type {
+		case 0x00:
+			chanInfo = append([]byte(nil), sp.Data...)
+			if channel, err = DecodeChannelInfo(chanInfo); err != nil {
+				return err
+			}
+		case 0x01:
+			playerInfo = append([]byte(nil), sp.Data...)
+			if persona, err = DecodePlayerInfo(playerInfo); err != nil {
+				return err
+			}
+			if invalid = persona.Validate(); invalid != nil {
+				playerInfo = nil
+			}
+		case 0x12:
+			if err := carPos.Update(append([]byte(nil), sp.Data...)); err != nil {
+				return err
 			}
 		}
 	}
+
+	c.LastPacket = time.Now()
+	c.established = true
+	c.net.onReceive(frame.Seq)
+	c.net.onAck(frame.SrvCounter, c.LastPacket)
+	c.acks.ack(frame.SrvCounter, c.slots)
 	var updated bool
-	data := packet[16 : len(packet)-5]
-	reader := bytes.NewReader(data)
-	for {
-		ptype, err := reader.ReadByte()
-		if err != nil {
-			break
-		}
-		plen, _ := reader.ReadByte()
-		innerData := make([]byte, plen)
-		reader.Read(innerData)
-		switch ptype {
+	for _, sp := range frame.Subpackets {
+		switch sp.Type {
 		case 0x00:
-			c.chanInfo = innerData
-			channelNameField := innerData[2:]
-			c.channelName = string(channelNameField[:cStrLen(channelNameField)])
-			c.socialFilteringEnabled = innerData[1] == 1
-			updated = true
+			if !c.enterInstance(channel.ChannelName) {
+				log.Printf("Rejecting channel change of %v; instance of channel %v is full", c.Addr.String(), channel.ChannelName)
+				continue
+			}
+			updated = updated || !bytes.Equal(chanInfo, c.chanInfo)
+			c.chanInfo = chanInfo
+			c.channel = channel
+			c.channelName = channel.ChannelName
+			c.socialFilteringEnabled = channel.SocialFiltering
 		case 0x01:
-			if c.allowedPersonas != nil {
-				personaID := binary.LittleEndian.Uint32(innerData[41:45])
-				var allowed bool
-				for _, id := range c.allowedPersonas {
-					if id == int(personaID) {
-						allowed = true
-						break
-					}
-				}
-				if !allowed {
-					fmt.Printf("Kicking %v; %v != %v\n", c.Addr.String(), personaID, c.allowedPersonas)
-					delete(c.clients, c.Addr.String())
-					return
+			if playerInfo == nil {
+				continue
+			}
+			personaID := persona.PersonaID
+			if c.sessionKey != nil && c.sessionKey.PersonaID != 0 {
+				if personaID != c.sessionKey.PersonaID {
+					log.Printf("Kicking %v; session key belongs to %v, not %v", c.Addr.String(), c.sessionKey.PersonaID, personaID)
+					c.detach()
+					return nil
 				}
 			}
-			c.playerInfo = innerData
-			nameField := innerData[1:33]
-			c.PersonaName = string(nameField[:cStrLen(nameField)])
-			updated = true
+			if !c.allowlist.Allowed(c.Addr.IP, personaID) {
+				log.Printf("Kicking %v; persona %v is not allowed", c.Addr.String(), personaID)
+				c.detach()
+				return nil
+			}
+			if ban, ok := c.bans.Check(c.Addr.IP, personaID); ok {
+				log.Printf("Kicking %v; %v is banned: %v", c.Addr.String(), ban.ID, ban.Reason)
+				c.detach()
+				return nil
+			}
+			c.personaID = personaID
+			c.updateSpectator()
+			c.updateSlotCapacity()
+			updated = updated || !bytes.Equal(playerInfo, c.playerInfo)
+			c.playerInfo = playerInfo
+			c.persona = persona
+			c.PersonaName = persona.PersonaName
 			//fmt.Printf("Player %s in channel %s; social filtering: %v\n", c.PersonaName, c.channelName, c.socialFilteringEnabled)
 		case 0x12:
-			if c.IsReady() && !bytes.Equal(innerData[2:], c.carPos.packet[2:]) {
-				updated = true
-			}
-			c.carPos.Update(innerData)
+			c.carPos = carPos
 			c.posRecvTD = c.getTimeDiff()
+			c.updateZone()
+			if !c.checkAnomalies(c.LastPacket) {
+				return nil
+			}
 		}
 	}
-	if c.IsReady() {
-		if updated {
-			c.registerUpdate()
-		}
-		c.sendPlayerSlots()
+	// Channel and player info changes are resent in full to everyone who has the client in a slot.
+	if c.ready() && updated {
+		c.registerUpdate()
 	}
+	c.publish()
+	return invalid
 }
 
 func b2i(b bool) int {
@@ -263,28 +429,118 @@ func b2i(b bool) int {
 	return 0
 }
 
-func (c *Client) getClosestPlayers(clients []*Client) []*Client {
-	closePlayers := make([]clientPosSortInfo, 0)
-	for _, client := range clients {
-		if !client.IsReady() || client.Addr == c.Addr {
+// getCandidates queries the spatial index for players that could be put into the client's slots.
+func (c *Client) getCandidates() []spatial.Neighbour[*Client] {
+	snap := c.shard.server.currentSnapshot()
+	blocked := c.shard.server.blocks.hiddenFrom(c.personaID)
+	keep := func(client *Client) bool {
+		if client == c || client.removed.Load() || !c.sameInstance(client) {
+			return false
+		}
+		_, ok := blocked[client.State().PersonaID]
+		return !ok
+	}
+	center := c.viewpoint(snap)
+	radius, exitRadius := c.radii()
+	if c.disableRadiusSync || (c.spectator != nil && c.spectator.IgnoreRadius) {
+		radius = 0
+	}
+	var out []spatial.Neighbour[*Client]
+	if !c.socialFilteringEnabled {
+		out = snap.index.Nearest(center, c.slotLimit(), radius, keep)
+	} else if radius > 0 {
+		out = snap.index.Query(center, radius, keep)
+	} else {
+		// Same-channel players are preferred regardless of distance, so everyone is a candidate.
+		out = make([]spatial.Neighbour[*Client], 0, snap.index.Len())
+		snap.index.Each(func(client *Client, pos math.Vector3D) {
+			if keep(client) {
+				out = append(out, spatial.Neighbour[*Client]{
+					Item:     client,
+					Pos:      pos,
+					Distance: snap.index.Distance(center, pos),
+				})
+			}
+		})
+	}
+
+	// Players that already have a slot keep being candidates up to the exit radius,
+	// and groupmates up to the group radius.
+	groupRadius := c.shard.server.udpConfig().GroupVisibilityRadius
+	if radius == 0 {
+		exitRadius, groupRadius = 0, 0
+	}
+	out = mergeCandidates(out, c.slotCandidates(snap, center, exitRadius, keep))
+	out = mergeCandidates(out, c.groupCandidates(snap, center, groupRadius, keep))
+	return out
+}
+
+// mergeCandidates appends the candidates in extra that aren't in out yet.
+func mergeCandidates(out, extra []spatial.Neighbour[*Client]) []spatial.Neighbour[*Client] {
+	for _, candidate := range extra {
+		if !slices.ContainsFunc(out, func(n spatial.Neighbour[*Client]) bool { return n.Item == candidate.Item }) {
+			out = append(out, candidate)
+		}
+	}
+	return out
+}
+
+// slotCandidates returns the players in the client's slots that are still in snap and for which
+// keep returns true, if they are within radius or got their slot less than minSlotTime ago.
+func (c *Client) slotCandidates(snap *worldSnapshot, center math.Vector3D, radius float64, keep func(*Client) bool) []spatial.Neighbour[*Client] {
+	out := make([]spatial.Neighbour[*Client], 0, len(c.slots))
+	for _, slot := range c.slots {
+		if slot == nil || !keep(slot.Client) {
 			continue
 		}
-		distance := math.Distance(c.GetPos(), client.GetPos())
-		
-		if !c.disableRadiusSync && distance > c.visibilityRadius {
+		pos, ok := snap.index.Pos(slot.Client)
+		if !ok {
 			continue
 		}
-		
+		distance := snap.index.Distance(center, pos)
+		if radius > 0 && distance > radius && !c.pinned(slot, snap.built) {
+			continue
+		}
+		out = append(out, spatial.Neighbour[*Client]{Item: slot.Client, Pos: pos, Distance: distance})
+	}
+	return out
+}
+
+// pinned returns true if the slot was added less than minSlotTime before now.
+func (c *Client) pinned(slot *slotInfo, now time.Time) bool {
+	return now.Sub(slot.added) < c.minSlotTime
+}
+
+func (c *Client) getClosestPlayers() []*Client {
+	candidates := c.getCandidates()
+	mates := c.shard.server.groupmates(c.personaID)
+	now := time.Now()
+	pinned := make(map[*Client]bool, len(c.slots))
+	for _, slot := range c.slots {
+		if slot != nil {
+			pinned[slot.Client] = c.pinned(slot, now)
+		}
+	}
+	closePlayers := make([]clientPosSortInfo, 0, len(candidates))
+	for _, candidate := range candidates {
+		state := candidate.Item.State()
+		_, group := mates[state.PersonaID]
 		closePlayers = append(closePlayers, clientPosSortInfo{
-			Length: int(distance),
-			Client: client,
+			Length:  int(candidate.Distance),
+			Client:  candidate.Item,
+			Channel: state.ChannelName,
+			Group:   group,
+			Pinned:  pinned[candidate.Item],
 		})
 	}
 
+	// Players that got their slot recently keep it, even if others are more relevant now.
 	if c.socialFilteringEnabled {
 		slices.SortStableFunc(closePlayers, func(a, b clientPosSortInfo) int {
 			return cmp.Or(
-				-cmp.Compare(b2i(a.Client.channelName == c.channelName), b2i(b.Client.channelName == c.channelName)),
+				-cmp.Compare(b2i(a.Pinned), b2i(b.Pinned)),
+				-cmp.Compare(b2i(a.Group), b2i(b.Group)),
+				-cmp.Compare(b2i(a.Channel == c.channelName), b2i(b.Channel == c.channelName)),
 				cmp.Compare(a.Length, b.Length))
 		})
 
@@ -295,12 +551,15 @@ func (c *Client) getClosestPlayers(clients []*Client) []*Client {
 		//fmt.Println()
 	} else {
 		slices.SortStableFunc(closePlayers, func(a, b clientPosSortInfo) int {
-			return cmp.Compare(a.Length, b.Length)
+			return cmp.Or(
+				-cmp.Compare(b2i(a.Pinned), b2i(b.Pinned)),
+				-cmp.Compare(b2i(a.Group), b2i(b.Group)),
+				cmp.Compare(a.Length, b.Length))
 		})
 	}
 	//sort.Sort(clientPosSort(closePlayers))
 
-	maxSlots := len(c.slots)
+	maxSlots := c.slotLimit()
 	out := make([]*Client, min(maxSlots, len(closePlayers)))
 	for i := range out {
 		out[i] = closePlayers[i].Client
@@ -331,8 +590,11 @@ func (c *Client) addSlot(client *Client) {
 	if index == -1 {
 		panic("addSlot: tried to add client with all slots full")
 	}
+	c.slotGen++
 	c.slots[index] = &slotInfo{
 		Client: client,
+		gen:    c.slotGen,
+		added:  time.Now(),
 	}
 }
 
@@ -360,28 +622,45 @@ func (c *Client) removeFromPendingQueue(client *Client) {
 	}
 }
 
-func (c *Client) startSpawnProcessor() {
-	c.spawnProcessorRunning = true
-	ticker := time.NewTicker(time.Duration(c.playerSpawnDelayMs) * time.Millisecond)
-	defer ticker.Stop()
+// SetTickRate overrides the rate at which the client receives slot packets.
+// A rate of 0 sends slot packets on every server tick.
+func (c *Client) SetTickRate(rate int) {
+	if rate < 0 {
+		rate = 0
+	}
+	c.tickRate = rate
+	c.nextBroadcast = time.Time{}
+}
 
-	for {
-		select {
-		case <-c.spawnProcessorStop:
-			c.spawnProcessorRunning = false
-			return
-		case <-ticker.C:
-			c.processNextPendingPlayer()
-		}
+// GetTickRate returns the client's tick rate override, or 0 if it follows the server tick.
+func (c *Client) GetTickRate() int {
+	return c.tickRate
+}
+
+// broadcast sends slot packets to the client if it is ready and its tick rate allows it.
+func (c *Client) broadcast(now time.Time) {
+	if !c.ready() || now.Before(c.nextBroadcast) {
+		return
+	}
+	if c.tickRate > 0 {
+		// Allow for some jitter of the server tick so that a client running at
+		// the server tick rate doesn't skip every other tick.
+		interval := tickInterval(c.tickRate)
+		c.nextBroadcast = now.Add(interval - interval/10)
 	}
+	c.sendPlayerSlots()
 }
 
-func (c *Client) stopSpawnProcessor() {
-	if c.spawnProcessorRunning {
-		c.spawnProcessorStop <- true
+// processPendingPlayers spawns the next pending player once the spawn delay has passed.
+func (c *Client) processPendingPlayers(now time.Time) {
+	if now.Before(c.nextSpawn) {
+		return
 	}
+	c.nextSpawn = now.Add(c.spawnDelay())
+	c.processNextPendingPlayer()
 }
 
+// processNextPendingPlayer gives the most relevant pending player a free slot, if there is one.
 func (c *Client) processNextPendingPlayer() {
 	c.pendingQueueMutex.Lock()
 	defer c.pendingQueueMutex.Unlock()
@@ -391,14 +670,16 @@ func (c *Client) processNextPendingPlayer() {
 	}
 
 	freeSlotIndex := -1
+	used := 0
 	for i, slot := range c.slots {
-		if slot == nil {
+		if slot != nil {
+			used++
+		} else if freeSlotIndex == -1 {
 			freeSlotIndex = i
-			break
 		}
 	}
 
-	if freeSlotIndex == -1 {
+	if freeSlotIndex == -1 || used >= c.slotLimit() {
 		return
 	}
 
@@ -407,8 +688,8 @@ func (c *Client) processNextPendingPlayer() {
 	c.addSlot(nextClient)
 }
 
-func (c *Client) recalculateSlots(clients []*Client) {
-	players := c.getClosestPlayers(clients)
+func (c *Client) recalculateSlots() {
+	players := c.getClosestPlayers()
 	oldPlayers := make([]*Client, 0)
 	for _, slot := range c.slots {
 		if slot != nil {
@@ -429,18 +710,27 @@ func (c *Client) recalculateSlots(clients []*Client) {
 			c.addToPendingQueue(addedClient)
 		}
 	}
+
+	// Players that are no longer candidates, e.g. because they were hidden, don't spawn.
+	// The others spawn in the order of their current relevance, so that the closest
+	// and socially closest players don't wait behind ones that were queued earlier.
+	rank := make(map[*Client]int, len(players))
+	for n, player := range players {
+		rank[player] = n
+	}
+	c.pendingQueueMutex.Lock()
+	c.pendingPlayerQueue = slices.DeleteFunc(c.pendingPlayerQueue, func(client *Client) bool {
+		_, ok := rank[client]
+		return !ok
+	})
+	slices.SortFunc(c.pendingPlayerQueue, func(a, b *Client) int {
+		return cmp.Compare(rank[a], rank[b])
+	})
+	c.pendingQueueMutex.Unlock()
 }
 
 func (c *Client) sendPlayerSlots() {
-	clients := make([]*Client, len(c.clients))
-	{
-		i := 0
-		for _, cl := range c.clients {
-			clients[i] = cl
-			i++
-		}
-	}
-	c.recalculateSlots(clients)
+	c.recalculateSlots()
 	buf := c.buffers.Get().(*bytes.Buffer)
 	buf.Reset()
 	seq := c.getSeq()
@@ -450,48 +740,64 @@ func (c *Client) sendPlayerSlots() {
 	binary.Write(buf, binary.BigEndian, c.tickDiff)
 	binary.Write(buf, binary.BigEndian, seq)
 	buf.Write([]byte{0xff, 0xff, 0x00})
-	fullsSent := 0
-	for _, slot := range c.slots {
+	c.writeSlots(buf, seq, time.Now())
+	c.trimSlots()
+	buf.Write([]byte{0x01, 0x01, 0x01, 0x01})
+	if c.sessionKey != nil {
+		if err := sealPacket(c.sessionKey.Key, buf.Bytes()); err != nil {
+			log.Printf("Error sealing packet for %v: %v", c.Addr.String(), err)
+			c.buffers.Put(buf)
+			return
+		}
+	}
+	c.SendRawPacket(buf.Bytes())
+	c.buffers.Put(buf)
+}
+
+// writeSlots writes the slot section of a slot packet with sequence number seq,
+// sending slots in full where needed and remembering them for ACK tracking.
+func (c *Client) writeSlots(buf *bytes.Buffer, seq uint16, now time.Time) {
+	rto := c.net.RTO()
+	fulls := make([]slotRef, 0, maxFullsPerPacket)
+	for index, slot := range c.slots {
 		if slot == nil {
 			buf.Write([]byte{0xff, 0xff})
 		} else {
+			state := slot.Client.State()
 			//pktTime := uint16(int(c.getTimeDiff()) - slot.Client.Ping)
-			if slot.HasSentFull && slot.Client.posRecvTD == slot.LastCPTime {
-				buf.Write([]byte{0x00, 0xff})
-			} else if fullsSent >= 3 {
-				slot.Client.writeFullPosPacket(buf)
-				slot.LastCPTime = slot.Client.posRecvTD
-			} else if !slot.HasSentFull {
-				slot.Client.writeFullSlotPacket(buf)
+			if slot.needsFull(state, now, rto) && len(fulls) < maxFullsPerPacket {
+				state.writeFullSlotPacket(buf)
 				slot.HasSentFull = true
+				slot.UpdateACKed = false
+				slot.LastUpdateID = state.updateID
 				slot.PacketSentSeq = seq
-				slot.LastCPTime = slot.Client.posRecvTD
-				fullsSent++
-			} else if slot.UpdateACKed || slot.ACKMissedCount < 5 {
-				slot.Client.writeFullPosPacket(buf)
-				slot.LastCPTime = slot.Client.posRecvTD
+				slot.PacketSentTime = now
+				slot.LastCPTime = state.posRecvTD
+				fulls = append(fulls, slotRef{index: index, gen: slot.gen, updateID: state.updateID})
+			} else if slot.HasSentFull && state.posRecvTD == slot.LastCPTime {
+				buf.Write([]byte{0x00, 0xff})
 			} else {
-				slot.Client.writeFullSlotPacket(buf)
-				slot.ACKMissedCount = 0
-				slot.PacketSentSeq = seq
-				slot.LastCPTime = slot.Client.posRecvTD
-				fullsSent++
+				state.writeFullPosPacket(buf)
+				slot.LastCPTime = state.posRecvTD
 			}
 		}
 	}
-	buf.Write([]byte{0x01, 0x01, 0x01, 0x01})
-	c.SendRawPacket(buf.Bytes())
-	c.buffers.Put(buf)
+	c.acks.record(seq, fulls)
 }
 
 // GetPos returns the current position of the client.
-func (c Client) GetPos() math.Vector2D {
-	return c.carPos.Pos()
+func (c *Client) GetPos() math.Vector2D {
+	return c.State().Pos
+}
+
+// GetCoordinates returns the current position of the client, including its height.
+func (c *Client) GetCoordinates() math.Vector3D {
+	return c.State().Coords
 }
 
 // GetRotation returns the current rotation of the client.
-func (c Client) GetRotation() float64 {
-	return c.carPos.Rotation()
+func (c *Client) GetRotation() float64 {
+	return c.State().Rotation
 }
 
 // SendRawPacket sends a raw UDP packet to the client.
@@ -502,20 +808,12 @@ func (c *Client) SendRawPacket(b []byte) error {
 
 // IsReady returns true if the client is ready to be broadcasted to other clients.
 // This means that the server has valid channel info, player info and position data of the client.
-func (c Client) IsReady() bool {
-	return c.chanInfo != nil && c.playerInfo != nil && c.carPos.Valid()
+func (c *Client) IsReady() bool {
+	return c.State().Ready()
 }
 
-func (c Client) writeFullPosPacket(buf *bytes.Buffer) {
-	buf.WriteByte(0x00) // Slot start
-	WriteSubpacket(buf, 0x12, c.carPos.Packet())
-	buf.WriteByte(0xff) // Slot end
-}
-
-func (c Client) writeFullSlotPacket(buf *bytes.Buffer) {
-	buf.WriteByte(0x00) // Slot start
-	WriteSubpacket(buf, 0x00, c.chanInfo)
-	WriteSubpacket(buf, 0x01, c.playerInfo)
-	WriteSubpacket(buf, 0x12, c.carPos.Packet())
-	buf.WriteByte(0xff) // Slot end
+// ready is like IsReady, but reads the client's own data instead of the published state.
+// It may only be called from the goroutine of the shard owning the client.
+func (c *Client) ready() bool {
+	return c.chanInfo != nil && c.playerInfo != nil && c.carPos.Valid()
 }
@@ -5,57 +5,67 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/WorldUnitedNFS/freeroam"
+	"github.com/WorldUnitedNFS/freeroam/api"
 	"github.com/WorldUnitedNFS/freeroam/fms"
 	"github.com/google/gops/agent"
 	"github.com/pelletier/go-toml"
 )
 
-func main() {
-	var config freeroam.Config
-
-	configFile, configErr := os.Open("config.toml")
-
-	if os.IsNotExist(configErr) {
-		config = freeroam.DefaultConfig()
-		configFile, configErr = os.Create("config.toml")
-
-		if configErr != nil {
-			log.Fatal(configErr)
-			return
-		}
+// shutdownTimeout is how long freeroamd waits for its servers to stop after a signal.
+const shutdownTimeout = 10 * time.Second
 
-		configMarshalled, configErr := toml.Marshal(config)
+// loadConfig reads config.toml, writing the default config first if it doesn't exist.
+// Settings missing from the file keep their default values.
+func loadConfig() (freeroam.Config, error) {
+	config := freeroam.DefaultConfig()
 
-		if configErr != nil {
-			log.Fatal(configErr)
-			return
+	configBytes, err := ioutil.ReadFile("config.toml")
+	if os.IsNotExist(err) {
+		configMarshalled, err := toml.Marshal(config)
+		if err != nil {
+			return config, err
 		}
-
-		_, configErr = configFile.Write(configMarshalled)
-		if configErr != nil {
-			log.Fatal(configErr)
-			return
+		if err := ioutil.WriteFile("config.toml", configMarshalled, 0644); err != nil {
+			return config, err
 		}
-
 		log.Print("Generated default config")
-	} else {
-		configBytes, configErr := ioutil.ReadFile("config.toml")
-		if configErr != nil {
-			log.Fatal(configErr)
-			return
-		}
+		return config, nil
+	}
+	if err != nil {
+		return config, err
+	}
+	err = toml.Unmarshal(configBytes, &config)
+	return config, err
+}
 
-		configErr = toml.Unmarshal(configBytes, &config)
+func main() {
+	if err := run(); err != nil {
+		log.Fatal(err)
+	}
+}
 
-		if configErr != nil {
-			log.Fatal(configErr)
-			return
+func run() error {
+	config, err := loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if config.API.ListenAddress != "" {
+		if err := api.ValidateConfig(config.API); err != nil {
+			return err
 		}
 	}
 
@@ -63,26 +73,68 @@ func main() {
 		log.Print(err)
 	}
 
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	i := freeroam.NewServer(config)
+	errs := make(chan error, 3)
+	httpServers := make([]*http.Server, 0, 2)
 
 	if config.FMS.ListenAddress != "" {
 		mapSrv := fms.NewMapServer(i, config.FMS)
 
 		fmsMux := http.NewServeMux()
 		fmsMux.HandleFunc("/ws", mapSrv.Handle)
+		fmsSrv := &http.Server{Addr: config.FMS.ListenAddress, Handler: fmsMux}
+		httpServers = append(httpServers, fmsSrv)
 
-		go mapSrv.Run()
+		go mapSrv.Run(ctx)
 		go func() {
 			log.Printf("Starting FMS on %v", config.FMS.ListenAddress)
-			err := http.ListenAndServe(config.FMS.ListenAddress, fmsMux)
-			if err != nil {
-				log.Fatal(err)
+			if err := fmsSrv.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+				errs <- err
 			}
 		}()
 	}
 
-	log.Printf("Starting server on %v", config.UDP.ListenAddress)
-	if err := i.Listen(config.UDP.ListenAddress); err != nil {
-		log.Fatal(err)
+	if config.API.ListenAddress != "" {
+		listener, err := api.Listen(config.API.ListenAddress)
+		if err != nil {
+			return err
+		}
+		apiSrv := &http.Server{Handler: api.NewServer(i, config.API)}
+		httpServers = append(httpServers, apiSrv)
+
+		go func() {
+			log.Printf("Starting API on %v", config.API.ListenAddress)
+			if err := apiSrv.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+				errs <- err
+			}
+		}()
+	}
+
+	go func() {
+		log.Printf("Starting server on %v", config.UDP.ListenAddress)
+		errs <- i.Serve(ctx)
+	}()
+
+	select {
+	case <-ctx.Done():
+		log.Print("Shutting down")
+	case err = <-errs:
+		log.Printf("Shutting down: %v", err)
+	}
+	stop()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	for _, srv := range httpServers {
+		if shutdownErr := srv.Shutdown(shutdownCtx); shutdownErr != nil && err == nil {
+			err = shutdownErr
+		}
+	}
+	if shutdownErr := i.Shutdown(shutdownCtx); shutdownErr != nil && err == nil {
+		err = shutdownErr
 	}
+	return err
 }
@@ -1,11 +1,93 @@
 package freeroam
 
+import "github.com/WorldUnitedNFS/freeroam/math"
+
 type UDPConfig struct {
 	ListenAddress         string
 	VisibilityRadius      float64
+	// VisibilityExitRadius is how far away a player can get before it loses its slot. It should
+	// be larger than VisibilityRadius, the distance at which players get a slot, so that players
+	// near the edge don't keep getting added and removed.
+	VisibilityExitRadius  float64
+	// VerticalWeight scales height differences in visibility distances. 1 measures plain 3D
+	// distances, larger values keep players on stacked roads apart and 0 ignores height.
+	VerticalWeight        float64
+	// MinSlotTimeMs is how long a player keeps its slot at least, as long as it is still in the world.
+	MinSlotTimeMs         int
 	MaxVisiblePlayers     int
 	PlayerSpawnDelayMs    int
 	DisableRadiusSync     bool
+	// Secure enables checksum verification and encryption of all client traffic.
+	Secure                bool
+	// Workers is the number of shards clients are spread across; 0 uses one per CPU.
+	Workers               int
+	// TickRate is how many times per second slot packets are sent to every client.
+	TickRate              int
+	// GroupVisibilityRadius is how far away party and crew members stay visible; 0 means unlimited.
+	GroupVisibilityRadius float64
+}
+
+type HandshakeConfig struct {
+	// MaxClients is the maximum number of connected clients; 0 means unlimited.
+	MaxClients int
+	// RatePerIP and BurstPerIP limit how many handshakes per second a single IP can send.
+	RatePerIP  float64
+	BurstPerIP int
+	// Rate and Burst limit how many handshakes per second the server accepts in total.
+	Rate  float64
+	Burst int
+	// ReconnectGraceMs is how long a client that has sent data has to be silent before a new
+	// handshake from its address may replace it, unless the handshake is authenticated by a session key.
+	ReconnectGraceMs int
+}
+
+type AnomalyConfig struct {
+	Enabled bool
+	// MaxSpeed is the highest plausible speed in m/s, implied by movement or reported by the client.
+	MaxSpeed float64
+	// TeleportDistance is the displacement in meters between two updates that counts as a teleport.
+	TeleportDistance float64
+	// WorldMin and WorldMax bound the playable area; the check is disabled while they are equal.
+	WorldMin math.Vector3D
+	WorldMax math.Vector3D
+	// Actions are "log", "hide" (from other players for HideDurationSec) or "kick".
+	SpeedAction      AnomalyAction
+	TeleportAction   AnomalyAction
+	OutOfWorldAction AnomalyAction
+	HideDurationSec  int
+}
+
+type AllowlistConfig struct {
+	// Personas may join from any IP. If empty, only the per-IP lists restrict who can join.
+	Personas []int
+	// PersonasByIP lists the personas that may join from an IP, in addition to Personas.
+	PersonasByIP map[string][]int
+}
+
+type BanConfig struct {
+	// File is the JSON file bans are stored in; it is reloaded when it changes.
+	// If empty, bans are only kept in memory.
+	File string
+}
+
+type InstanceConfig struct {
+	// Strict makes players only see others in the same instance. Each channel is an
+	// instance of its own, unless it is part of one of Groups.
+	Strict bool
+	// MaxPlayers caps the number of players in each channel that isn't part of a group;
+	// 0 means unlimited. Players switching to a channel of a full instance stay where they were.
+	MaxPlayers int
+	Groups     []ChannelGroup
+}
+
+// ChannelGroup joins several channels into one instance. Channels listed in more than
+// one group belong to the first of them.
+type ChannelGroup struct {
+	// Name identifies the instance; it shouldn't be the name of a channel outside the group.
+	Name     string
+	Channels []string
+	// MaxPlayers caps the number of players in the group; 0 means unlimited.
+	MaxPlayers int
 }
 
 type FMSConfig struct {
@@ -14,23 +96,83 @@ type FMSConfig struct {
 	UpdateInterval int
 }
 
+type APIConfig struct {
+	// ListenAddress is a TCP address, or "unix:" followed by a socket path.
+	ListenAddress string
+	// Token must be sent as a bearer token by API clients. It may only be empty if the
+	// API listens on a unix socket.
+	Token string
+	// SessionKeyTTL is the lifetime of registered session keys in seconds.
+	SessionKeyTTL int
+}
+
 type Config struct {
-	UDP UDPConfig
-	FMS FMSConfig
+	UDP       UDPConfig
+	Handshake HandshakeConfig
+	Anomaly   AnomalyConfig
+	Allowlist AllowlistConfig
+	Bans      BanConfig
+	// Zones override visibility settings in parts of the map. Where zones overlap,
+	// the first one applies.
+	Zones []ZoneConfig
+	// Instances splits the server into isolated instances by channel.
+	Instances InstanceConfig
+	// Spectators lists the personas that have the spectator role when the server starts.
+	Spectators []Spectator
+	// SlotCapacities gives personas a number of slots other than UDP.MaxVisiblePlayers.
+	SlotCapacities []SlotCapacity
+	FMS       FMSConfig
+	API       APIConfig
+}
+
+// Validate checks the settings that can't fall back to a sensible default.
+func (c Config) Validate() error {
+	return c.Anomaly.Validate()
 }
 
 func DefaultConfig() Config {
 	return Config{
 		UDP: UDPConfig{
-			ListenAddress:      ":9999",
-			VisibilityRadius:   300.0,
-			MaxVisiblePlayers:  14,
-			PlayerSpawnDelayMs: 200,
-			DisableRadiusSync:  false,
+			ListenAddress:         ":9999",
+			VisibilityRadius:      300.0,
+			VisibilityExitRadius:  350.0,
+			MinSlotTimeMs:         2000,
+			VerticalWeight:        1,
+			MaxVisiblePlayers:     14,
+			PlayerSpawnDelayMs:    200,
+			DisableRadiusSync:     false,
+			Secure:                false,
+			Workers:               0,
+			TickRate:              20,
+			GroupVisibilityRadius: 1500,
+		},
+		Handshake: HandshakeConfig{
+			MaxClients:       1000,
+			RatePerIP:        1,
+			BurstPerIP:       5,
+			Rate:             100,
+			Burst:            200,
+			ReconnectGraceMs: 3000,
+		},
+		Anomaly: AnomalyConfig{
+			Enabled:          true,
+			MaxSpeed:         150,
+			TeleportDistance: 500,
+			SpeedAction:      ActionLog,
+			TeleportAction:   ActionLog,
+			OutOfWorldAction: ActionLog,
+			HideDurationSec:  60,
+		},
+		Bans: BanConfig{
+			File: "bans.json",
 		},
 		FMS: FMSConfig{
 			ListenAddress: "127.0.0.1:6996",
 			AllowedOrigin: "127.0.0.1",
 		},
+		API: APIConfig{
+			ListenAddress: "",
+			SessionKeyTTL: 3600,
+		},
 	}
 }
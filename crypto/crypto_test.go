@@ -61,3 +61,22 @@ func TestPacketEncrypt(t *testing.T) {
 		t.Errorf("Expected encrypted payload to be [8f 6c 09], got: %s", hex.Dump(encryptedPayload))
 	}
 }
+
+func TestPacketRoundTrip(t *testing.T) {
+	sessionKey, err := ParseSessionKey("FMb88lBCpYFqrEzsigPNVA==")
+	if err != nil {
+		t.Error(err)
+	}
+	testPayload := bytes.Repeat([]byte{0x00, 0x2f, 0x7a, 0x12}, 20)
+	encryptedPayload, err := sessionKey.Cipher(testPayload, 1234)
+	if err != nil {
+		t.Error(err)
+	}
+	decryptedPayload, err := sessionKey.Decipher(encryptedPayload, 1234)
+	if err != nil {
+		t.Error(err)
+	}
+	if !bytes.Equal(decryptedPayload, testPayload) {
+		t.Errorf("Expected round trip to return the original payload, got: %s", hex.Dump(decryptedPayload))
+	}
+}
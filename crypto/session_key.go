@@ -120,3 +120,19 @@ func (sk SessionKey) Cipher(input []byte, seq uint16) ([]byte, error) {
 	stream.XORKeyStream(output, input)
 	return output, nil
 }
+
+// Reverses the cipher operation for a packet body.
+func (sk SessionKey) Decipher(input []byte, seq uint16) ([]byte, error) {
+	block, err := aes.NewCipher(sk.CryptKey)
+	if err != nil {
+		return nil, err
+	}
+	iv, err := sk.GenerateIv(seq)
+	if err != nil {
+		return nil, err
+	}
+	output := make([]byte, len(input))
+	stream := cipher.NewCFBDecrypter(block, iv)
+	stream.XORKeyStream(output, input)
+	return output, nil
+}
@@ -4,17 +4,34 @@
 
 package freeroam
 
+import "time"
+
 type slotInfo struct {
 	Client         *Client
+	gen            uint32
+	added          time.Time
 	LastUpdateID   uint8
 	UpdateACKed    bool
 	HasSentFull    bool
-	ACKMissedCount uint8
 	PacketSentSeq  uint16
+	PacketSentTime time.Time
 
 	LastCPTime uint16
 }
 
+// maxFullsPerPacket limits how many slots are sent in full in a single packet.
+const maxFullsPerPacket = 3
+
+// needsFull returns true if the channel info, player info and position of the slot's
+// player have to be sent, because they were never sent, have changed, or weren't
+// ACKed within the retransmission timeout.
+func (s *slotInfo) needsFull(state *PlayerState, now time.Time, rto time.Duration) bool {
+	if !s.HasSentFull || s.LastUpdateID != state.updateID {
+		return true
+	}
+	return !s.UpdateACKed && now.Sub(s.PacketSentTime) >= rto
+}
+
 type ArrayDiffResult struct {
 	Kept    []*Client
 	Added   []*Client
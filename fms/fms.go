@@ -5,6 +5,7 @@
 package fms
 
 import (
+	"context"
 	"log"
 	"math"
 	"net/http"
@@ -62,11 +63,29 @@ func (s *MapServer) Handle(w http.ResponseWriter, r *http.Request) {
 	s.conns[c.RemoteAddr().String()] = c
 }
 
-func (s *MapServer) Run() {
-	updateInterval := s.UpdateInterval
+// Run sends player positions to all map clients until ctx is cancelled,
+// then closes their connections.
+func (s *MapServer) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.UpdateInterval * time.Millisecond)
+	defer ticker.Stop()
 	for {
-		s.SendPlayers()
-		time.Sleep(updateInterval * time.Millisecond)
+		select {
+		case <-ctx.Done():
+			s.Close()
+			return
+		case <-ticker.C:
+			s.SendPlayers()
+		}
+	}
+}
+
+// Close closes all map client connections.
+func (s *MapServer) Close() {
+	s.Lock()
+	defer s.Unlock()
+	for addr, conn := range s.conns {
+		conn.Close()
+		delete(s.conns, addr)
 	}
 }
 
@@ -77,20 +96,15 @@ func (s *MapServer) SendPlayers() {
 		return
 	}
 
-	s.i.Lock()
 	players := s.players[:0]
-	for _, c := range s.i.Clients {
-		if c.IsReady() {
-			pos := c.GetPos()
-			players = append(players, PlayerInfo{
-				Name:     c.PersonaName,
-				X:        int(math.Round(pos.X)),
-				Y:        int(math.Round(pos.Y)),
-				Rotation: int(math.Round(c.GetRotation())),
-			})
-		}
+	for _, p := range s.i.Players() {
+		players = append(players, PlayerInfo{
+			Name:     p.PersonaName,
+			X:        int(math.Round(p.Pos.X)),
+			Y:        int(math.Round(p.Pos.Y)),
+			Rotation: int(math.Round(p.Rotation)),
+		})
 	}
-	s.i.Unlock()
 
 	for addr, conn := range s.conns {
 		err := conn.WriteJSON(players)
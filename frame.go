@@ -0,0 +1,102 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	frameHeaderLen  = 16
+	frameTrailerLen = 5
+)
+
+// Minimum payload lengths of the subpackets the server looks into.
+const (
+	minChanInfoLen   = chanInfoNameOffset
+	minPlayerInfoLen = playerInfoLen
+	minCarPosLen     = 2
+)
+
+var ErrFrameTooShort = errors.New("frame shorter than header and trailer")
+
+// FrameError describes why a client packet couldn't be parsed.
+type FrameError struct {
+	// Offset is the position in the packet at which parsing failed.
+	Offset int
+	Msg    string
+}
+
+func (e *FrameError) Error() string {
+	return fmt.Sprintf("malformed frame at offset %d: %s", e.Offset, e.Msg)
+}
+
+// Subpacket is a typed chunk of a frame body.
+type Subpacket struct {
+	Type uint8
+	Data []byte
+}
+
+// Frame is a parsed packet sent by a client.
+// Its slices point into the parsed packet and must be copied before the packet is reused.
+type Frame struct {
+	Header []byte
+	// Seq is the client's own sequence number of the packet.
+	Seq uint16
+	// SrvCounter is the sequence number of the last server packet the client received.
+	SrvCounter uint16
+	Subpackets []Subpacket
+	Trailer    []byte
+}
+
+// ParseFrame splits a client packet into its header, subpackets and trailer.
+// Subpackets the server reads from are checked for their minimum length.
+func ParseFrame(packet []byte) (Frame, error) {
+	if len(packet) < frameHeaderLen+frameTrailerLen {
+		return Frame{}, ErrFrameTooShort
+	}
+	end := len(packet) - frameTrailerLen
+	frame := Frame{
+		Header:     packet[:frameHeaderLen],
+		Seq:        binary.BigEndian.Uint16(packet[0:2]),
+		SrvCounter: binary.BigEndian.Uint16(packet[8:10]),
+		Subpackets: make([]Subpacket, 0, 4),
+		Trailer:    packet[end:],
+	}
+	for offset := frameHeaderLen; offset < end; {
+		if offset+2 > end {
+			return Frame{}, &FrameError{Offset: offset, Msg: "truncated subpacket header"}
+		}
+		typ := packet[offset]
+		length := int(packet[offset+1])
+		start := offset + 2
+		if start+length > end {
+			return Frame{}, &FrameError{Offset: offset, Msg: fmt.Sprintf("subpacket 0x%02x length %d exceeds frame", typ, length)}
+		}
+		if min := subpacketMinLen(typ); length < min {
+			return Frame{}, &FrameError{Offset: offset, Msg: fmt.Sprintf("subpacket 0x%02x length %d below minimum %d", typ, length, min)}
+		}
+		frame.Subpackets = append(frame.Subpackets, Subpacket{
+			Type: typ,
+			Data: packet[start : start+length],
+		})
+		offset = start + length
+	}
+	return frame, nil
+}
+
+func subpacketMinLen(typ uint8) int {
+	switch typ {
+	case 0x00:
+		return minChanInfoLen
+	case 0x01:
+		return minPlayerInfoLen
+	case 0x12:
+		return minCarPosLen
+	}
+	return 0
+}
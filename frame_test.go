@@ -0,0 +1,110 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestParseFrame(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	packet := testPacket(0x1234,
+		testSubpacket(0x00, testChanInfo("channel")),
+		testSubpacket(0x12, testCarPos(rng)),
+	)
+	frame, err := ParseFrame(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.SrvCounter != 0x1234 {
+		t.Errorf("Expected server counter 0x1234, got 0x%04x", frame.SrvCounter)
+	}
+	if len(frame.Subpackets) != 2 || frame.Subpackets[0].Type != 0x00 || frame.Subpackets[1].Type != 0x12 {
+		t.Errorf("Expected channel info and car position subpackets, got %v", frame.Subpackets)
+	}
+
+	if _, err := ParseFrame(packet[:10]); !errors.Is(err, ErrFrameTooShort) {
+		t.Errorf("Expected ErrFrameTooShort, got %v", err)
+	}
+	truncated := testPacket(0, []byte{0x12, 0x30, 0x00})
+	var frameErr *FrameError
+	if _, err := ParseFrame(truncated); !errors.As(err, &frameErr) || frameErr.Offset != 16 {
+		t.Errorf("Expected FrameError at offset 16, got %v", err)
+	}
+	shortInfo := testPacket(0, testSubpacket(0x01, make([]byte, 10)))
+	if _, err := ParseFrame(shortInfo); err == nil {
+		t.Error("Expected short player info to be rejected")
+	}
+}
+
+func FuzzProcessPacket(f *testing.F) {
+	rng := rand.New(rand.NewSource(1))
+	f.Add(testPacket(0,
+		testSubpacket(0x00, testChanInfo("channel")),
+		testSubpacket(0x01, testPlayerInfo("player", 1)),
+		testSubpacket(0x12, testCarPos(rng)),
+	))
+	f.Add(testPacket(0, testSubpacket(0x12, []byte{0x00})))
+	f.Add(testPacket(0, []byte{0x01}))
+	f.Add(testHello(0))
+	f.Add([]byte{})
+
+	i := NewServer(DefaultConfig())
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		f.Fatal(err)
+	}
+	defer conn.Close()
+	i.listener = conn
+	s := i.shards[0]
+	addr := testAddr(0)
+	s.handlePacket(addr, testHello(0))
+	client := s.clients[addr.String()]
+	f.Fuzz(func(t *testing.T, data []byte) {
+		s.handlePacket(addr, data)
+		if current, ok := s.clients[addr.String()]; ok {
+			client = current
+		}
+		i.buildSnapshot()
+		client.broadcast(time.Now())
+	})
+}
+
+func TestMalformedPacketLeavesStateUntouched(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	i := newTestServer(t, 2)
+	populate(i, 1, rng)
+	client := testClient(i, 0)
+	var lastPacket time.Time
+	i.execClient(testAddr(0).String(), func(client *Client) {
+		lastPacket = client.LastPacket
+	})
+	coords := client.GetCoordinates()
+
+	i.inject(testAddr(0), testPacket(1,
+		testSubpacket(0x00, testChanInfo("other")),
+		testSubpacket(0x12, testCarPos(rng)),
+		[]byte{0x12, 0x30, 0x00},
+	))
+	i.wait()
+	if malformed := client.MalformedPackets(); malformed != 1 {
+		t.Errorf("Expected 1 malformed packet, got %d", malformed)
+	}
+	i.execClient(testAddr(0).String(), func(client *Client) {
+		if !client.LastPacket.Equal(lastPacket) {
+			t.Error("Expected a malformed packet to not count as activity")
+		}
+		if client.channelName != "channel" {
+			t.Errorf("Expected channel to stay unchanged, got %q", client.channelName)
+		}
+	})
+	if client.GetCoordinates() != coords {
+		t.Error("Expected a malformed packet to not move the client")
+	}
+}
@@ -0,0 +1,110 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/WorldUnitedNFS/freeroam/math"
+	"github.com/WorldUnitedNFS/freeroam/spatial"
+)
+
+// Group is a party or crew registered by the core server. Members of a group get each
+// other's slots before anyone else and stay visible up to UDPConfig.GroupVisibilityRadius.
+type Group struct {
+	// ID identifies the group; its format is up to the core server, e.g. "party:12" or "crew:34".
+	ID      string `json:"id"`
+	Members []int  `json:"members"`
+}
+
+// groupIndex maps each persona to the personas it shares a group with.
+// It is rebuilt whenever a group changes and never modified afterwards.
+type groupIndex map[int]map[int]struct{}
+
+// SetGroup registers a group, replacing any group with the same ID.
+// A group without members is removed.
+func (i *Server) SetGroup(group Group) {
+	i.groupLock.Lock()
+	defer i.groupLock.Unlock()
+	if len(group.Members) == 0 {
+		delete(i.groups, group.ID)
+	} else {
+		i.groups[group.ID] = slices.Clone(group.Members)
+	}
+	i.rebuildGroupIndex()
+}
+
+// RemoveGroup removes a group. It returns false if there was no such group.
+func (i *Server) RemoveGroup(id string) bool {
+	i.groupLock.Lock()
+	defer i.groupLock.Unlock()
+	_, ok := i.groups[id]
+	delete(i.groups, id)
+	i.rebuildGroupIndex()
+	return ok
+}
+
+// Groups returns all groups, ordered by ID.
+func (i *Server) Groups() []Group {
+	i.groupLock.Lock()
+	defer i.groupLock.Unlock()
+	out := make([]Group, 0, len(i.groups))
+	for id, members := range i.groups {
+		out = append(out, Group{ID: id, Members: slices.Clone(members)})
+	}
+	slices.SortFunc(out, func(a, b Group) int {
+		return strings.Compare(a.ID, b.ID)
+	})
+	return out
+}
+
+// rebuildGroupIndex must be called with groupLock held.
+func (i *Server) rebuildGroupIndex() {
+	index := make(groupIndex)
+	for _, members := range i.groups {
+		for _, a := range members {
+			for _, b := range members {
+				if a == b {
+					continue
+				}
+				if index[a] == nil {
+					index[a] = make(map[int]struct{})
+				}
+				index[a][b] = struct{}{}
+			}
+		}
+	}
+	i.groupIndex.Store(&index)
+}
+
+// groupmates returns the personas that share a group with personaID. The result must not be modified.
+func (i *Server) groupmates(personaID int) map[int]struct{} {
+	return (*i.groupIndex.Load())[personaID]
+}
+
+// groupCandidates returns the client's groupmates in snap that are within radius of center
+// and for which keep returns true.
+func (c *Client) groupCandidates(snap *worldSnapshot, center math.Vector3D, radius float64, keep func(*Client) bool) []spatial.Neighbour[*Client] {
+	mates := c.shard.server.groupmates(c.personaID)
+	out := make([]spatial.Neighbour[*Client], 0, len(mates))
+	for personaID := range mates {
+		state, ok := snap.byPersona[personaID]
+		if !ok || !keep(state.Client) {
+			continue
+		}
+		// Hidden players are in the snapshot, but not in the index.
+		pos, ok := snap.index.Pos(state.Client)
+		if !ok {
+			continue
+		}
+		distance := snap.index.Distance(center, pos)
+		if radius > 0 && distance > radius {
+			continue
+		}
+		out = append(out, spatial.Neighbour[*Client]{Item: state.Client, Pos: pos, Distance: distance})
+	}
+	return out
+}
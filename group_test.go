@@ -0,0 +1,50 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestGroups(t *testing.T) {
+	config := instantSlotsConfig(2)
+	config.UDP.MaxVisiblePlayers = 2
+	config.UDP.GroupVisibilityRadius = 1000
+	i := newTestServerWithConfig(t, config)
+	populate(i, 5, rand.New(rand.NewSource(1)))
+	place(i, 0, 0, 0)
+	place(i, 1, 10, 0)
+	place(i, 2, 20, 0)
+	place(i, 3, 800, 0)
+	place(i, 4, 2000, 0)
+	near, mate, farMate := testClient(i, 1), testClient(i, 3), testClient(i, 4)
+
+	if visible := visibleTo(i, 0); !visible[near] || visible[mate] {
+		t.Fatalf("Expected the closest players to be visible, got %v", visible)
+	}
+
+	// Personas are numbered from 1, so persona 4 is testAddr(3).
+	i.SetGroup(Group{ID: "party:1", Members: []int{1, 4, 5}})
+	if visible := visibleTo(i, 0); !visible[mate] || !visible[near] {
+		t.Errorf("Expected groupmate beyond the radius to get a slot first, got %v", visible)
+	}
+	if visible := visibleTo(i, 0); visible[farMate] {
+		t.Error("Expected groupmate beyond the group radius to not be visible")
+	}
+	if visible := visibleTo(i, 3); !visible[testClient(i, 0)] {
+		t.Error("Expected grouping to work both ways")
+	}
+
+	if !i.RemoveGroup("party:1") {
+		t.Error("Expected group to be removed")
+	}
+	if visible := visibleTo(i, 0); visible[mate] {
+		t.Error("Expected former groupmate to be out of range again")
+	}
+	if groups := i.Groups(); len(groups) != 0 {
+		t.Errorf("Expected no groups, got %v", groups)
+	}
+}
@@ -0,0 +1,119 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+// instanceRegistry tracks which instance each channel belongs to and how many players
+// are in each instance. It is only used when InstanceConfig.Strict is set.
+type instanceRegistry struct {
+	strict     bool
+	maxPlayers int
+	// groups maps each grouped channel to its group.
+	groups map[string]*ChannelGroup
+	counts map[string]int
+}
+
+func newInstanceRegistry(config InstanceConfig) *instanceRegistry {
+	r := &instanceRegistry{
+		strict:     config.Strict,
+		maxPlayers: config.MaxPlayers,
+		groups:     make(map[string]*ChannelGroup),
+		counts:     make(map[string]int),
+	}
+	for n := range config.Groups {
+		group := config.Groups[n]
+		for _, channel := range group.Channels {
+			if _, ok := r.groups[channel]; !ok {
+				r.groups[channel] = &group
+			}
+		}
+	}
+	return r
+}
+
+// instanceOf returns the name of the instance a channel belongs to and its player cap.
+func (r *instanceRegistry) instanceOf(channel string) (string, int) {
+	if group, ok := r.groups[channel]; ok {
+		return group.Name, group.MaxPlayers
+	}
+	return channel, r.maxPlayers
+}
+
+// enterInstance moves the client into the instance of channel.
+// It returns false if that instance is full, in which case the client stays where it was.
+func (c *Client) enterInstance(channel string) bool {
+	server := c.shard.server
+	if !server.instances.strict {
+		return true
+	}
+	name, maxPlayers := server.instances.instanceOf(channel)
+	if c.inInstance && c.instance == name {
+		return true
+	}
+	server.instanceLock.Lock()
+	defer server.instanceLock.Unlock()
+	if maxPlayers > 0 && server.instances.counts[name] >= maxPlayers {
+		return false
+	}
+	if c.inInstance {
+		server.leaveInstanceLocked(c.instance)
+	}
+	server.instances.counts[name]++
+	c.instance = name
+	c.inInstance = true
+	return true
+}
+
+// leaveInstance frees the client's place in its instance.
+func (c *Client) leaveInstance() {
+	if !c.inInstance {
+		return
+	}
+	server := c.shard.server
+	server.instanceLock.Lock()
+	server.leaveInstanceLocked(c.instance)
+	server.instanceLock.Unlock()
+	c.inInstance = false
+}
+
+// leaveInstanceLocked must be called with instanceLock held.
+func (i *Server) leaveInstanceLocked(name string) {
+	i.instances.counts[name]--
+	if i.instances.counts[name] <= 0 {
+		delete(i.instances.counts, name)
+	}
+}
+
+// InstanceCounts returns the number of players in each instance.
+// It is empty unless strict instances are enabled.
+func (i *Server) InstanceCounts() map[string]int {
+	i.instanceLock.Lock()
+	defer i.instanceLock.Unlock()
+	out := make(map[string]int, len(i.instances.counts))
+	for name, count := range i.instances.counts {
+		out[name] = count
+	}
+	return out
+}
+
+// Instance returns the name of the instance the client is in, or an empty string if
+// strict instances are disabled.
+func (c *Client) Instance() string {
+	return c.State().Instance
+}
+
+func (c *Client) instanceName() string {
+	if !c.inInstance {
+		return ""
+	}
+	return c.instance
+}
+
+// sameInstance returns true if the client may see other. Without strict instances, everyone may.
+func (c *Client) sameInstance(other *Client) bool {
+	if !c.shard.server.instances.strict {
+		return true
+	}
+	return c.inInstance && other.State().Instance == c.instance
+}
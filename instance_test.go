@@ -0,0 +1,59 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestStrictInstances(t *testing.T) {
+	config := DefaultConfig()
+	config.UDP.Workers = 2
+	config.Instances = InstanceConfig{
+		Strict: true,
+		Groups: []ChannelGroup{{Name: "event", Channels: []string{"a", "b"}, MaxPlayers: 2}},
+	}
+	i := newTestServerWithConfig(t, config)
+	populate(i, 4, rand.New(rand.NewSource(1)))
+	for n := 0; n < 4; n++ {
+		place(i, n, float64(n*10), 0)
+	}
+	if visible := visibleTo(i, 0); len(visible) != 3 {
+		t.Fatalf("Expected everyone in the same channel to be visible, got %d players", len(visible))
+	}
+
+	for n, channel := range []string{"a", "b", "c", "a"} {
+		i.inject(testAddr(n), testPacket(1, testSubpacket(0x00, testChanInfo(channel))))
+		i.wait()
+	}
+	if count := i.ClientCount(); count != 4 {
+		t.Errorf("Expected the player joining a full instance to stay connected, got %d clients", count)
+	}
+	counts := i.InstanceCounts()
+	if len(counts) != 3 || counts["event"] != 2 || counts["c"] != 1 || counts["channel"] != 1 {
+		t.Errorf("Unexpected instance counts %v", counts)
+	}
+	if instance := testClient(i, 3).Instance(); instance != "channel" {
+		t.Errorf("Expected the player rejected by a full instance to stay in its channel, got %q", instance)
+	}
+	if instance := testClient(i, 1).Instance(); instance != "event" {
+		t.Errorf("Expected player in channel b to be in the event instance, got %q", instance)
+	}
+
+	if visible := visibleTo(i, 0); len(visible) != 1 || !visible[testClient(i, 1)] {
+		t.Errorf("Expected only the other player in the channel group to be visible, got %v", visible)
+	}
+	if visible := visibleTo(i, 2); len(visible) != 0 {
+		t.Errorf("Expected no one to be visible in an instance of its own, got %v", visible)
+	}
+
+	// Leaving frees a place in the instance.
+	i.inject(testAddr(1), testPacket(2, testSubpacket(0x00, testChanInfo("c"))))
+	i.wait()
+	if counts := i.InstanceCounts(); counts["event"] != 1 || counts["c"] != 2 {
+		t.Errorf("Unexpected instance counts after changing channels %v", counts)
+	}
+}
@@ -18,6 +18,22 @@ type Vector3D struct {
 	Z float64
 }
 
+// Magnitude returns the length of a Vector3D
+func Magnitude(v Vector3D) float64 {
+	return math.Sqrt(v.X*v.X + v.Y*v.Y + v.Z*v.Z)
+}
+
+// Distance3D returns Euclidean distance between two Vector3Ds
+func Distance3D(a, b Vector3D) float64 {
+	return Magnitude(Vector3D{X: a.X - b.X, Y: a.Y - b.Y, Z: a.Z - b.Z})
+}
+
+// WeightedDistance3D returns the distance between two Vector3Ds with the Z difference
+// multiplied by verticalWeight. A weight of 1 gives the Euclidean distance.
+func WeightedDistance3D(a, b Vector3D, verticalWeight float64) float64 {
+	return Magnitude(Vector3D{X: a.X - b.X, Y: a.Y - b.Y, Z: (a.Z - b.Z) * verticalWeight})
+}
+
 // Distance returns Euclidean distance between two Vectors
 func Distance(a, b Vector2D) float64 {
 	xd := a.X - b.X
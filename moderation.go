@@ -0,0 +1,119 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	ErrNoTarget  = errors.New("address or persona ID is required")
+	ErrNoPersona = errors.New("client hasn't sent its persona yet")
+)
+
+// Hidden players are left out of other clients' slots, but still appear in the
+// player list used by the FMS and the API. Ghosts are left out of both.
+// Both keep receiving slot packets, so they can't tell that they're invisible.
+
+// hide hides the client from other clients until the given time.
+// A zero time makes the client visible again.
+func (c *Client) hide(until time.Time) {
+	c.hiddenUntil = until
+	c.publish()
+}
+
+// ghost removes the client from the world as seen by everyone else until the given time.
+// A zero time makes the client visible again.
+func (c *Client) ghost(until time.Time) {
+	c.ghostUntil = until
+	c.publish()
+}
+
+// execTarget runs fn on the goroutine of the shard owning each client at address, or
+// using personaID if address is empty. It returns the number of matching clients.
+func (i *Server) execTarget(address string, personaID int, fn func(client *Client)) (int, error) {
+	if address != "" {
+		if i.execClient(address, fn) {
+			return 1, nil
+		}
+		return 0, nil
+	}
+	if personaID == 0 {
+		return 0, ErrNoTarget
+	}
+	var count atomic.Int64
+	i.forEachClient(func(client *Client) {
+		if client.personaID == personaID {
+			fn(client)
+			count.Add(1)
+		}
+	})
+	return int(count.Load()), nil
+}
+
+// expiry returns the end of an action lasting d from now, or the zero time if d isn't positive.
+func expiry(d time.Duration) time.Time {
+	if d <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(d)
+}
+
+// Kick disconnects the clients at address, or using personaID if address is empty,
+// and returns how many were kicked. If duration is positive, the persona is banned for
+// that long so it can't rejoin right away. A client that hasn't sent its persona yet
+// can't be banned without banning everyone else on its IP, so it is left connected
+// and ErrNoPersona is returned instead.
+func (i *Server) Kick(address string, personaID int, duration time.Duration, reason string) (int, error) {
+	var lock sync.Mutex
+	var kicked []Ban
+	var refused atomic.Bool
+	count, err := i.execTarget(address, personaID, func(client *Client) {
+		if duration > 0 && client.personaID == 0 {
+			refused.Store(true)
+			return
+		}
+		log.Printf("Kicking %v; %v", client.Addr.String(), reason)
+		lock.Lock()
+		kicked = append(kicked, Ban{PersonaID: client.personaID, Reason: reason})
+		lock.Unlock()
+		client.detach()
+	})
+	if err == nil && refused.Load() {
+		return 0, ErrNoPersona
+	}
+	if err != nil || duration <= 0 {
+		return count, err
+	}
+	for _, ban := range kicked {
+		ban.Expires = expiry(duration)
+		if _, err := i.Ban(ban); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// Hide hides the clients at address, or using personaID if address is empty, from other
+// players for duration and returns how many were hidden. A duration of 0 unhides them.
+func (i *Server) Hide(address string, personaID int, duration time.Duration) (int, error) {
+	end := expiry(duration)
+	return i.execTarget(address, personaID, func(client *Client) {
+		client.hide(end)
+	})
+}
+
+// Ghost turns the clients at address, or using personaID if address is empty, into ghosts
+// for duration and returns how many were affected. A duration of 0 makes them visible again.
+func (i *Server) Ghost(address string, personaID int, duration time.Duration) (int, error) {
+	end := expiry(duration)
+	return i.execTarget(address, personaID, func(client *Client) {
+		client.ghost(end)
+	})
+}
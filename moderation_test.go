@@ -0,0 +1,95 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestHideAndGhost(t *testing.T) {
+	config := DefaultConfig()
+	config.UDP.Workers = 2
+	config.UDP.DisableRadiusSync = true
+	i := newTestServerWithConfig(t, config)
+	populate(i, 3, rand.New(rand.NewSource(1)))
+	first, second, third := testClient(i, 0), testClient(i, 1), testClient(i, 2)
+	if visible := visibleTo(i, 0); !visible[second] || !visible[third] {
+		t.Fatalf("Expected all players to be visible, got %v", visible)
+	}
+
+	if n, _ := i.Hide(testAddr(1).String(), 0, time.Minute); n != 1 {
+		t.Fatalf("Expected 1 client to be hidden, got %d", n)
+	}
+	if visible := visibleTo(i, 0); visible[second] || !visible[third] {
+		t.Error("Expected hidden player to be removed from slots")
+	}
+	if len(i.Players()) != 3 {
+		t.Error("Expected hidden player to stay in the player list")
+	}
+	if visible := visibleTo(i, 1); !visible[first] {
+		t.Error("Expected hidden player to still see others")
+	}
+
+	if n, _ := i.Ghost("", 3, time.Minute); n != 1 {
+		t.Fatalf("Expected 1 client to become a ghost, got %d", n)
+	}
+	if visible := visibleTo(i, 0); visible[third] {
+		t.Error("Expected ghost to be removed from slots")
+	}
+	if len(i.Players()) != 2 {
+		t.Error("Expected ghost to be removed from the player list")
+	}
+	if visible := visibleTo(i, 2); !visible[first] {
+		t.Error("Expected ghost to still see others")
+	}
+
+	i.Hide(testAddr(1).String(), 0, 0)
+	i.Ghost("", 3, 0)
+	if visible := visibleTo(i, 0); !visible[second] || !visible[third] {
+		t.Error("Expected lifted actions to make players visible again")
+	}
+	if _, err := i.Hide("", 0, time.Minute); err != ErrNoTarget {
+		t.Errorf("Expected ErrNoTarget, got %v", err)
+	}
+}
+
+func TestKick(t *testing.T) {
+	config := DefaultConfig()
+	config.UDP.Workers = 2
+	config.Bans.File = ""
+	i := newTestServerWithConfig(t, config)
+	rng := rand.New(rand.NewSource(1))
+	populate(i, 3, rng)
+
+	if n, _ := i.Kick("", 1, time.Minute, "test"); n != 1 {
+		t.Fatalf("Expected 1 client to be kicked, got %d", n)
+	}
+	if n, _ := i.Kick(testAddr(1).String(), 0, 0, "test"); n != 1 {
+		t.Fatalf("Expected 1 client to be kicked, got %d", n)
+	}
+	if count := i.ClientCount(); count != 1 {
+		t.Errorf("Expected 1 client left, got %d", count)
+	}
+	// Only the persona that was kicked with a duration is kept out.
+	populate(i, 3, rng)
+	if count := i.ClientCount(); count != 2 {
+		t.Errorf("Expected 2 clients after rejoining, got %d", count)
+	}
+
+	// A client without a persona can't be banned without banning its whole IP.
+	i.inject(testAddr(3), testHello(3))
+	i.wait()
+	if n, err := i.Kick(testAddr(3).String(), 0, time.Minute, "test"); n != 0 || err != ErrNoPersona {
+		t.Errorf("Expected a timed kick of a client without a persona to be refused, got %d, %v", n, err)
+	}
+	if count := i.ClientCount(); count != 3 {
+		t.Errorf("Expected the client without a persona to stay connected, got %d clients", count)
+	}
+	if bans := i.Bans.List(); len(bans) != 1 || bans[0].PersonaID != 1 {
+		t.Errorf("Expected only the kicked persona to be banned, got %+v", bans)
+	}
+}
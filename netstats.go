@@ -0,0 +1,150 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import "time"
+
+// sentHistory is how many sent sequence numbers are remembered for RTT measurement.
+const sentHistory = 256
+
+const (
+	// initialRTO is used for retransmission decisions until the first RTT sample arrives.
+	initialRTO = 1 * time.Second
+	minRTO     = 100 * time.Millisecond
+	maxRTO     = 2 * time.Second
+)
+
+// NetStats describes the connection quality of a client.
+type NetStats struct {
+	// RTT is the smoothed round-trip time. It includes the time the client waits
+	// before echoing the latest server sequence number back.
+	RTT time.Duration `json:"rtt"`
+	// Jitter is the mean deviation between consecutive RTT samples.
+	Jitter time.Duration `json:"jitter"`
+	// Loss is the estimated fraction of client packets that never arrived.
+	Loss    float64 `json:"loss"`
+	Samples int     `json:"samples"`
+}
+
+// seqNewer returns true if sequence number a comes after b, taking uint16 wraparound into account.
+func seqNewer(a, b uint16) bool {
+	return int16(a-b) > 0
+}
+
+type sentPacket struct {
+	seq   uint16
+	at    time.Time
+	valid bool
+}
+
+// netEstimator derives RTT, jitter and loss from sequence numbers.
+// The server records the send time of every sequence number, and the client echoes the
+// latest one it received in each packet. Loss is estimated from gaps in the client's own
+// sequence numbers.
+type netEstimator struct {
+	sent [sentHistory]sentPacket
+
+	hasAck  bool
+	lastAck uint16
+
+	samples    int
+	srtt       time.Duration
+	rttvar     time.Duration
+	jitter     time.Duration
+	lastSample time.Duration
+
+	hasClientSeq  bool
+	lastClientSeq uint16
+	loss          float64
+}
+
+func (n *netEstimator) onSend(seq uint16, now time.Time) {
+	n.sent[seq%sentHistory] = sentPacket{seq: seq, at: now, valid: true}
+}
+
+// onAck processes the server sequence number echoed by the client.
+// Only the first echo of each sequence number yields an RTT sample, since later
+// packets carrying the same number have been delayed by the client.
+func (n *netEstimator) onAck(seq uint16, now time.Time) {
+	if n.hasAck && !seqNewer(seq, n.lastAck) {
+		return
+	}
+	n.hasAck = true
+	n.lastAck = seq
+	sent := n.sent[seq%sentHistory]
+	if !sent.valid || sent.seq != seq {
+		return
+	}
+	n.addSample(now.Sub(sent.at))
+}
+
+// addSample updates the RTT estimate as described in RFC 6298 and the jitter as in RFC 3550.
+func (n *netEstimator) addSample(rtt time.Duration) {
+	if n.samples == 0 {
+		n.srtt = rtt
+		n.rttvar = rtt / 2
+	} else {
+		n.rttvar += (absDuration(n.srtt-rtt) - n.rttvar) / 4
+		n.srtt += (rtt - n.srtt) / 8
+		n.jitter += (absDuration(rtt-n.lastSample) - n.jitter) / 16
+	}
+	n.lastSample = rtt
+	n.samples++
+}
+
+// onReceive processes the sequence number of a client packet.
+func (n *netEstimator) onReceive(seq uint16) {
+	if !n.hasClientSeq {
+		n.hasClientSeq = true
+		n.lastClientSeq = seq
+		return
+	}
+	if !seqNewer(seq, n.lastClientSeq) {
+		// Duplicate or reordered; it was already counted as lost, so take that back.
+		n.loss -= n.loss / 32
+		return
+	}
+	gap := int(seq-n.lastClientSeq) - 1
+	n.lastClientSeq = seq
+	if gap > sentHistory {
+		// Probably a restart of the client's counter rather than actual loss.
+		return
+	}
+	for i := 0; i < gap; i++ {
+		n.loss += (1 - n.loss) / 32
+	}
+	n.loss -= n.loss / 32
+}
+
+// RTO returns how long to wait for an ACK before retransmitting.
+func (n *netEstimator) RTO() time.Duration {
+	if n.samples == 0 {
+		return initialRTO
+	}
+	rto := n.srtt + 4*n.rttvar
+	if rto < minRTO {
+		return minRTO
+	}
+	if rto > maxRTO {
+		return maxRTO
+	}
+	return rto
+}
+
+func (n *netEstimator) stats() NetStats {
+	return NetStats{
+		RTT:     n.srtt,
+		Jitter:  n.jitter,
+		Loss:    n.loss,
+		Samples: n.samples,
+	}
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
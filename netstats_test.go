@@ -0,0 +1,52 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNetEstimatorRTT(t *testing.T) {
+	var n netEstimator
+	start := time.Now()
+	for i := 0; i < 100; i++ {
+		seq := uint16(65500 + i)
+		sent := start.Add(time.Duration(i) * 50 * time.Millisecond)
+		n.onSend(seq, sent)
+		n.onAck(seq, sent.Add(80*time.Millisecond))
+		// Later packets echoing the same sequence number must not skew the estimate.
+		n.onAck(seq, sent.Add(120*time.Millisecond))
+	}
+	stats := n.stats()
+	if stats.Samples != 100 {
+		t.Errorf("Expected 100 samples, got %d", stats.Samples)
+	}
+	if stats.RTT < 79*time.Millisecond || stats.RTT > 81*time.Millisecond {
+		t.Errorf("Expected RTT of 80ms, got %v", stats.RTT)
+	}
+	if stats.Jitter > time.Millisecond {
+		t.Errorf("Expected no jitter, got %v", stats.Jitter)
+	}
+	if rto := n.RTO(); rto != minRTO {
+		t.Errorf("Expected RTO to be clamped to %v, got %v", minRTO, rto)
+	}
+}
+
+func TestNetEstimatorLoss(t *testing.T) {
+	var n netEstimator
+	seq := uint16(65000)
+	for i := 0; i < 2000; i++ {
+		n.onReceive(seq)
+		// Every fifth packet is lost.
+		if i%4 == 3 {
+			seq++
+		}
+		seq++
+	}
+	if loss := n.stats().Loss; loss < 0.15 || loss > 0.25 {
+		t.Errorf("Expected loss of about 20%%, got %f", loss)
+	}
+}
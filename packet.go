@@ -7,6 +7,7 @@ package freeroam
 import (
 	"bytes"
 	"encoding/binary"
+	"fmt"
 	"github.com/WorldUnitedNFS/freeroam/carstate"
 	"github.com/WorldUnitedNFS/freeroam/math"
 )
@@ -25,6 +26,8 @@ type CarPosPacket struct {
 	time     uint16
 	packet   []byte
 	pos      math.Vector2D
+	coords   math.Vector3D
+	velocity math.Vector3D
 	rotation float64
 }
 
@@ -38,6 +41,21 @@ func (p *CarPosPacket) Pos() math.Vector2D {
 	return p.pos
 }
 
+// Coordinates returns the full car position as a Vector3D.
+func (p *CarPosPacket) Coordinates() math.Vector3D {
+	return p.coords
+}
+
+// Velocity returns the linear velocity reported by the client in m/s.
+func (p *CarPosPacket) Velocity() math.Vector3D {
+	return p.velocity
+}
+
+// SimTime returns the client simulation time of the packet in milliseconds.
+func (p *CarPosPacket) SimTime() uint16 {
+	return p.time
+}
+
 // Rotation returns the car rotation in degrees.
 func (p *CarPosPacket) Rotation() float64 {
 	return p.rotation
@@ -51,18 +69,25 @@ func (p *CarPosPacket) Packet() []byte {
 
 // Update updates CarPosPacket with the specified byte slice.
 // The supplied slice shouldn't be modified after calling this method.
-func (p *CarPosPacket) Update(packet []byte) {
-	p.time = binary.BigEndian.Uint16(packet[0:2])
-	p.packet = packet
+// If the packet can't be decoded, CarPosPacket is left unchanged.
+func (p *CarPosPacket) Update(packet []byte) error {
+	if len(packet) < 2 {
+		return fmt.Errorf("car position packet too short (%d bytes)", len(packet))
+	}
 	reader := carstate.NewPacketReader(packet)
 	decodedPacket, err := reader.Decode()
 
 	if err != nil {
-		panic(err)
+		return err
 	}
 
+	p.time = binary.BigEndian.Uint16(packet[0:2])
+	p.packet = packet
 	coords := decodedPacket.Coordinates()
 	p.pos.X = coords.X
 	p.pos.Y = coords.Y
+	p.coords = coords
+	p.velocity = decodedPacket.LinearVelocity()
 	p.rotation = decodedPacket.Rotation()
+	return nil
 }
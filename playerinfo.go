@@ -0,0 +1,96 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Layout of the 0x01 player info subpacket. Only the persona name and ID are known;
+// the other bytes are kept as they are so that the subpacket can be encoded again.
+const (
+	playerInfoNameOffset    = 1
+	playerInfoNameLen       = 32
+	playerInfoUnknownOffset = playerInfoNameOffset + playerInfoNameLen
+	playerInfoPersonaOffset = 41
+	playerInfoLen           = playerInfoPersonaOffset + 4
+)
+
+var (
+	ErrPlayerInfoTooShort = errors.New("player info too short")
+	ErrPersonaNameTooLong = errors.New("persona name too long")
+	ErrInvalidPersonaName = errors.New("invalid persona name")
+	ErrInvalidPersonaID   = errors.New("invalid persona ID")
+)
+
+// PlayerInfo is the content of a 0x01 player info subpacket.
+type PlayerInfo struct {
+	// Flags is the first byte of the subpacket; its meaning is unknown.
+	Flags       byte
+	PersonaName string
+	// Unknown holds the bytes between the persona name and ID.
+	Unknown   [playerInfoPersonaOffset - playerInfoUnknownOffset]byte
+	PersonaID int
+	// Extra holds the bytes after the persona ID. The car and driver level are somewhere
+	// in there, but their offsets and encoding haven't been identified from client captures
+	// yet. PlayerInfo deliberately has no car or level fields until they have been; the
+	// bytes are kept as is rather than decoded from a guessed layout.
+	Extra []byte
+}
+
+// DecodePlayerInfo decodes a player info subpacket. The result shares no memory with data.
+// It only checks the length, so player info from clients has to be validated as well.
+func DecodePlayerInfo(data []byte) (PlayerInfo, error) {
+	var info PlayerInfo
+	if len(data) < playerInfoLen {
+		return info, fmt.Errorf("%w (%d bytes)", ErrPlayerInfoTooShort, len(data))
+	}
+	info.Flags = data[0]
+	name := data[playerInfoNameOffset : playerInfoNameOffset+playerInfoNameLen]
+	info.PersonaName = string(name[:cStrLen(name)])
+	copy(info.Unknown[:], data[playerInfoUnknownOffset:playerInfoPersonaOffset])
+	info.PersonaID = int(binary.LittleEndian.Uint32(data[playerInfoPersonaOffset:playerInfoLen]))
+	if len(data) > playerInfoLen {
+		info.Extra = append([]byte(nil), data[playerInfoLen:]...)
+	}
+	return info, nil
+}
+
+// Validate checks that the player info can be encoded and describes a real persona.
+func (p PlayerInfo) Validate() error {
+	if len(p.PersonaName) >= playerInfoNameLen {
+		return ErrPersonaNameTooLong
+	}
+	if p.PersonaName == "" {
+		return ErrInvalidPersonaName
+	}
+	for i := 0; i < len(p.PersonaName); i++ {
+		if c := p.PersonaName[i]; c < 0x20 || c > 0x7e {
+			return ErrInvalidPersonaName
+		}
+	}
+	if p.PersonaID <= 0 || uint64(p.PersonaID) > 0xffffffff {
+		return ErrInvalidPersonaID
+	}
+	if playerInfoLen+len(p.Extra) > 255 {
+		return fmt.Errorf("player info too long (%d bytes)", playerInfoLen+len(p.Extra))
+	}
+	return nil
+}
+
+// Encode returns the player info as subpacket data. The persona name is padded with zeros.
+func (p PlayerInfo) Encode() ([]byte, error) {
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	data := make([]byte, playerInfoLen, playerInfoLen+len(p.Extra))
+	data[0] = p.Flags
+	copy(data[playerInfoNameOffset:], p.PersonaName)
+	copy(data[playerInfoUnknownOffset:], p.Unknown[:])
+	binary.LittleEndian.PutUint32(data[playerInfoPersonaOffset:], uint32(p.PersonaID))
+	return append(data, p.Extra...), nil
+}
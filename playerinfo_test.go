@@ -0,0 +1,91 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"bytes"
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+)
+
+func TestPlayerInfo(t *testing.T) {
+	data := testPlayerInfo("Driver", 1234)
+	data[0] = 0x02
+	data[35] = 0x7f
+	data[50] = 0x55
+
+	info, err := DecodePlayerInfo(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.PersonaName != "Driver" || info.PersonaID != 1234 || info.Flags != 0x02 {
+		t.Errorf("Unexpected player info %+v", info)
+	}
+	if info.Unknown[2] != 0x7f || len(info.Extra) != len(data)-playerInfoLen || info.Extra[5] != 0x55 {
+		t.Error("Expected unknown bytes to be kept")
+	}
+
+	encoded, err := info.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encoded, data) {
+		t.Errorf("Expected encoding to round-trip:\n%x\n%x", data, encoded)
+	}
+	data[50] = 0
+	if info.Extra[5] != 0x55 {
+		t.Error("Expected decoded player info to not share memory with its input")
+	}
+}
+
+func TestPlayerInfoValidation(t *testing.T) {
+	if _, err := DecodePlayerInfo(make([]byte, playerInfoLen-1)); !errors.Is(err, ErrPlayerInfoTooShort) {
+		t.Errorf("Expected ErrPlayerInfoTooShort, got %v", err)
+	}
+	tests := []struct {
+		info PlayerInfo
+		err  error
+	}{
+		{PlayerInfo{PersonaName: "Driver", PersonaID: 1}, nil},
+		{PlayerInfo{PersonaName: "", PersonaID: 1}, ErrInvalidPersonaName},
+		{PlayerInfo{PersonaName: "Dri\x00ver", PersonaID: 1}, ErrInvalidPersonaName},
+		{PlayerInfo{PersonaName: strings.Repeat("a", 32), PersonaID: 1}, ErrPersonaNameTooLong},
+		{PlayerInfo{PersonaName: "Driver", PersonaID: 0}, ErrInvalidPersonaID},
+	}
+	for _, test := range tests {
+		if _, err := test.info.Encode(); err != test.err {
+			t.Errorf("Expected %v for %+v, got %v", test.err, test.info, err)
+		}
+	}
+	if _, err := (PlayerInfo{PersonaName: "Driver", PersonaID: 1, Extra: make([]byte, 255)}).Encode(); err == nil {
+		t.Error("Expected oversized player info to be rejected")
+	}
+}
+
+func TestInvalidPlayerInfoRejected(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	i := newTestServer(t, 2)
+	populate(i, 1, rng)
+	client := testClient(i, 0)
+	for _, info := range [][]byte{testPlayerInfo("nobody", 0), testPlayerInfo("bad\x01name", 2)} {
+		coords := client.GetCoordinates()
+		i.inject(testAddr(0), testPacket(0,
+			testSubpacket(0x01, info),
+			testSubpacket(0x12, testCarPos(rng)),
+		))
+		i.wait()
+		if client.GetCoordinates() == coords {
+			t.Error("Expected the car position next to invalid player info to be applied")
+		}
+	}
+	if malformed := client.MalformedPackets(); malformed != 2 {
+		t.Errorf("Expected invalid player info to be rejected, got %d malformed packets", malformed)
+	}
+	if info := client.PlayerInfo(); info.PersonaName != "player0" || info.PersonaID != 1 {
+		t.Errorf("Expected the previous player info to be kept, got %+v", info)
+	}
+}
@@ -0,0 +1,102 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"net"
+	"time"
+)
+
+// tokenBucket is a rate limiter that allows bursts of up to burst events
+// and refills at rate events per second. A burst below 1 is treated as 1.
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+func bucketSize(burst int) float64 {
+	if burst < 1 {
+		return 1
+	}
+	return float64(burst)
+}
+
+// ready refills the bucket and returns true if it holds a whole token.
+func (b *tokenBucket) ready(now time.Time, rate float64, burst int) bool {
+	if rate <= 0 {
+		return true
+	}
+	size := bucketSize(burst)
+	if b.last.IsZero() {
+		b.tokens = size
+	} else {
+		b.tokens += now.Sub(b.last).Seconds() * rate
+		if b.tokens > size {
+			b.tokens = size
+		}
+	}
+	b.last = now
+	return b.tokens >= 1
+}
+
+// take spends a token. It must only be called after ready returned true.
+func (b *tokenBucket) take(rate float64) {
+	if rate > 0 {
+		b.tokens--
+	}
+}
+
+func (b *tokenBucket) allow(now time.Time, rate float64, burst int) bool {
+	if !b.ready(now, rate, burst) {
+		return false
+	}
+	b.take(rate)
+	return true
+}
+
+// full returns true if the bucket would be back at its burst size by now,
+// meaning it carries no state worth keeping.
+func (b *tokenBucket) full(now time.Time, rate float64, burst int) bool {
+	return b.tokens+now.Sub(b.last).Seconds()*rate >= bucketSize(burst)
+}
+
+// handshakeLimiter limits the rate of handshakes per source IP and globally.
+// It is only used by the packet reader goroutine and is not safe for concurrent use.
+type handshakeLimiter struct {
+	perIP     map[string]*tokenBucket
+	global    tokenBucket
+	lastPrune time.Time
+}
+
+func newHandshakeLimiter() *handshakeLimiter {
+	return &handshakeLimiter{
+		perIP: make(map[string]*tokenBucket),
+	}
+}
+
+func (l *handshakeLimiter) allow(addr *net.UDPAddr, now time.Time, config HandshakeConfig) bool {
+	if now.Sub(l.lastPrune) > 10*time.Second {
+		for ip, bucket := range l.perIP {
+			if bucket.full(now, config.RatePerIP, config.BurstPerIP) {
+				delete(l.perIP, ip)
+			}
+		}
+		l.lastPrune = now
+	}
+	ip := addr.IP.String()
+	bucket, ok := l.perIP[ip]
+	if !ok {
+		bucket = &tokenBucket{}
+		l.perIP[ip] = bucket
+	}
+	// Tokens are only spent if both buckets have one, so that handshakes rejected by the
+	// global limit don't count against the IP.
+	if !bucket.ready(now, config.RatePerIP, config.BurstPerIP) || !l.global.ready(now, config.Rate, config.Burst) {
+		return false
+	}
+	bucket.take(config.RatePerIP)
+	l.global.take(config.Rate)
+	return true
+}
@@ -0,0 +1,48 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketZeroBurst(t *testing.T) {
+	var b tokenBucket
+	now := time.Now()
+	if !b.allow(now, 1, 0) {
+		t.Fatal("Expected a burst of 0 to allow one event")
+	}
+	if b.allow(now, 1, 0) {
+		t.Error("Expected the second event to be limited")
+	}
+	if !b.allow(now.Add(time.Second), 1, 0) {
+		t.Error("Expected the bucket to refill")
+	}
+}
+
+func TestHandshakeLimiterGlobalFirst(t *testing.T) {
+	l := newHandshakeLimiter()
+	config := HandshakeConfig{RatePerIP: 1, BurstPerIP: 2, Rate: 1, Burst: 1}
+	a := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 1}
+	b := &net.UDPAddr{IP: net.IPv4(10, 0, 0, 2), Port: 1}
+	now := time.Now()
+	if !l.allow(b, now, config) {
+		t.Fatal("Expected the first handshake to be allowed")
+	}
+	// The global bucket is empty now, so these must not drain a's bucket.
+	for n := 0; n < 3; n++ {
+		if l.allow(a, now, config) {
+			t.Fatal("Expected handshakes to be limited globally")
+		}
+	}
+	if !l.allow(a, now.Add(time.Second), config) {
+		t.Error("Expected a's bucket to be untouched by globally rejected handshakes")
+	}
+	if !l.allow(a, now.Add(2*time.Second), config) {
+		t.Error("Expected a's burst to still be available")
+	}
+}
@@ -0,0 +1,214 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/WorldUnitedNFS/freeroam/crypto"
+)
+
+// In secure mode every packet keeps its sequence number and packet type in the clear,
+// the rest of the body is ciphered with the session key and the last four bytes carry
+// a little-endian checksum of everything before them.
+// The handshake is secured the same way, so its initial tick can only be read after
+// decrypting it, and the handshake reply is sealed like every other packet.
+const (
+	secureHeaderLen   = 3
+	secureChecksumLen = 4
+	// maxSeqJump is how far ahead of the last accepted sequence number a secured packet may be.
+	maxSeqJump = 1024
+)
+
+var (
+	ErrPacketTooShort  = errors.New("packet too short for secure mode")
+	ErrChecksumInvalid = errors.New("packet checksum mismatch")
+	ErrReplayedPacket  = errors.New("packet sequence number is not newer than the last accepted one")
+)
+
+// SessionKeyEntry is a session key registered by the core server.
+type SessionKeyEntry struct {
+	// ID identifies the entry; it is the address the key was registered for,
+	// or "persona:<id>" for keys that are only bound to a persona.
+	ID        string
+	Key       crypto.SessionKey
+	PersonaID int
+	Expires   time.Time
+}
+
+func (e SessionKeyEntry) expired(now time.Time) bool {
+	return !e.Expires.IsZero() && now.After(e.Expires)
+}
+
+// SessionKeyStore holds the session keys of clients that are allowed to connect in secure mode.
+type SessionKeyStore struct {
+	sync.RWMutex
+	keys map[string]SessionKeyEntry
+}
+
+func NewSessionKeyStore() *SessionKeyStore {
+	return &SessionKeyStore{
+		keys: make(map[string]SessionKeyEntry),
+	}
+}
+
+// SessionKeyID returns the ID under which a key for the given address or persona is stored.
+// The address takes precedence; it can either be a full "ip:port" pair or just an IP.
+func SessionKeyID(addr string, personaID int) string {
+	if addr != "" {
+		return addr
+	}
+	return "persona:" + strconv.Itoa(personaID)
+}
+
+// Set registers a session key, replacing any key previously stored under the same ID.
+// A zero ttl means that the key never expires.
+func (s *SessionKeyStore) Set(addr string, personaID int, key crypto.SessionKey, ttl time.Duration) SessionKeyEntry {
+	entry := SessionKeyEntry{
+		ID:        SessionKeyID(addr, personaID),
+		Key:       key,
+		PersonaID: personaID,
+	}
+	if ttl > 0 {
+		entry.Expires = time.Now().Add(ttl)
+	}
+	s.Lock()
+	defer s.Unlock()
+	s.keys[entry.ID] = entry
+	return entry
+}
+
+// Remove revokes the session key stored under an ID. It returns false if there was no such key.
+func (s *SessionKeyStore) Remove(id string) bool {
+	s.Lock()
+	defer s.Unlock()
+	_, ok := s.keys[id]
+	delete(s.keys, id)
+	return ok
+}
+
+// Len returns the number of registered keys, including expired ones that were not pruned yet.
+func (s *SessionKeyStore) Len() int {
+	s.RLock()
+	defer s.RUnlock()
+	return len(s.keys)
+}
+
+// Prune removes all expired keys.
+func (s *SessionKeyStore) Prune() {
+	now := time.Now()
+	s.Lock()
+	defer s.Unlock()
+	for id, entry := range s.keys {
+		if entry.expired(now) {
+			delete(s.keys, id)
+		}
+	}
+}
+
+// Lookup returns the session key for a client handshake. An exact "ip:port" match is
+// preferred over an IP-only match; keys that are only bound to a persona are tried
+// against the handshake checksum.
+func (s *SessionKeyStore) Lookup(addr *net.UDPAddr, hello []byte) (SessionKeyEntry, bool) {
+	now := time.Now()
+	s.RLock()
+	defer s.RUnlock()
+	for _, id := range []string{addr.String(), addr.IP.String()} {
+		if entry, ok := s.keys[id]; ok && !entry.expired(now) {
+			return entry, true
+		}
+	}
+	for id, entry := range s.keys {
+		if id != SessionKeyID("", entry.PersonaID) || entry.expired(now) {
+			continue
+		}
+		if verifyPacket(entry.Key, hello) == nil {
+			return entry, true
+		}
+	}
+	return SessionKeyEntry{}, false
+}
+
+// seqGuard rejects secured packets whose sequence number isn't newer than the last
+// accepted one, so that captured packets can't be replayed.
+type seqGuard struct {
+	last    uint16
+	started bool
+}
+
+// check returns ErrReplayedPacket unless seq is at most maxSeqJump ahead of the last
+// accepted sequence number, allowing for wraparound.
+func (g *seqGuard) check(seq uint16) error {
+	if !g.started {
+		return nil
+	}
+	if d := seq - g.last; d == 0 || d > maxSeqJump {
+		return ErrReplayedPacket
+	}
+	return nil
+}
+
+// accept records seq as the last accepted sequence number.
+func (g *seqGuard) accept(seq uint16) {
+	g.last = seq
+	g.started = true
+}
+
+// verifyPacket checks the trailing checksum of a secured packet.
+func verifyPacket(key crypto.SessionKey, packet []byte) error {
+	if len(packet) < secureHeaderLen+secureChecksumLen {
+		return ErrPacketTooShort
+	}
+	end := len(packet) - secureChecksumLen
+	sum, err := key.GenerateChecksum(packet[:end])
+	if err != nil {
+		return err
+	}
+	if sum != binary.LittleEndian.Uint32(packet[end:]) {
+		return ErrChecksumInvalid
+	}
+	return nil
+}
+
+// openPacket verifies and decrypts a secured packet in place.
+func openPacket(key crypto.SessionKey, packet []byte) error {
+	if err := verifyPacket(key, packet); err != nil {
+		return err
+	}
+	end := len(packet) - secureChecksumLen
+	seq := binary.BigEndian.Uint16(packet[0:2])
+	plain, err := key.Decipher(packet[secureHeaderLen:end], seq)
+	if err != nil {
+		return err
+	}
+	copy(packet[secureHeaderLen:end], plain)
+	return nil
+}
+
+// sealPacket encrypts a packet in place and fills in its trailing checksum.
+// The last four bytes of the packet are reserved for the checksum.
+func sealPacket(key crypto.SessionKey, packet []byte) error {
+	if len(packet) < secureHeaderLen+secureChecksumLen {
+		return ErrPacketTooShort
+	}
+	end := len(packet) - secureChecksumLen
+	seq := binary.BigEndian.Uint16(packet[0:2])
+	cipherText, err := key.Cipher(packet[secureHeaderLen:end], seq)
+	if err != nil {
+		return err
+	}
+	copy(packet[secureHeaderLen:end], cipherText)
+	sum, err := key.GenerateChecksum(packet[:end])
+	if err != nil {
+		return err
+	}
+	binary.LittleEndian.PutUint32(packet[end:], sum)
+	return nil
+}
@@ -0,0 +1,225 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/WorldUnitedNFS/freeroam/crypto"
+)
+
+func testSessionKey(tb testing.TB) crypto.SessionKey {
+	key, err := crypto.ParseSessionKey("FMb88lBCpYFqrEzsigPNVA==")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return key
+}
+
+// sealTestPacket sets the sequence number of a copy of packet and seals it with key.
+func sealTestPacket(tb testing.TB, key crypto.SessionKey, seq uint16, packet []byte) []byte {
+	out := append([]byte(nil), packet...)
+	binary.BigEndian.PutUint16(out[0:2], seq)
+	if err := sealPacket(key, out); err != nil {
+		tb.Fatal(err)
+	}
+	return out
+}
+
+func newSecureTestServer(tb testing.TB) *Server {
+	config := DefaultConfig()
+	config.UDP.Workers = 2
+	config.UDP.Secure = true
+	return newTestServerWithConfig(tb, config)
+}
+
+func TestSecureHello(t *testing.T) {
+	i := newSecureTestServer(t)
+	key := testSessionKey(t)
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	addr := conn.LocalAddr().(*net.UDPAddr)
+	i.Keys.Set(addr.String(), 0, key, 0)
+
+	i.inject(addr, sealTestPacket(t, key, 0, testHello(0x1234)))
+	i.wait()
+	var tick uint16
+	if !i.execClient(addr.String(), func(client *Client) {
+		tick = client.initialTick
+	}) {
+		t.Fatal("Expected sealed handshake to be accepted")
+	}
+	if tick != 0x1234 {
+		t.Errorf("Expected initial tick 0x1234 from the decrypted handshake, got %#04x", tick)
+	}
+
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reply := buf[:n]
+	if err := openPacket(key, reply); err != nil {
+		t.Fatalf("Expected handshake reply to be sealed: %v", err)
+	}
+	if reply[2] != 0x01 {
+		t.Errorf("Expected handshake reply type 0x01, got %#02x", reply[2])
+	}
+}
+
+func TestSecureReplay(t *testing.T) {
+	i := newSecureTestServer(t)
+	key := testSessionKey(t)
+	addr := testAddr(0)
+	i.Keys.Set(addr.String(), 0, key, 0)
+	i.inject(addr, sealTestPacket(t, key, 0, testHello(0)))
+	i.wait()
+	client := testClient(i, 0)
+
+	packet := testPacket(0, testSubpacket(0x00, testChanInfo("channel")))
+	send := func(seq uint16) {
+		i.inject(addr, sealTestPacket(t, key, seq, packet))
+		i.wait()
+	}
+	send(1)
+	send(2)
+	if rejected := client.RejectedPackets(); rejected != 0 {
+		t.Fatalf("Expected newer packets to be accepted, got %d rejected", rejected)
+	}
+	send(2)
+	send(1)
+	if rejected := client.RejectedPackets(); rejected != 2 {
+		t.Errorf("Expected replayed packets to be rejected, got %d rejected", rejected)
+	}
+	send(2 + maxSeqJump + 1)
+	if rejected := client.RejectedPackets(); rejected != 3 {
+		t.Errorf("Expected packets too far ahead to be rejected, got %d rejected", rejected)
+	}
+}
+
+func TestSeqGuardWraparound(t *testing.T) {
+	var g seqGuard
+	if err := g.check(0xfff0); err != nil {
+		t.Errorf("Expected the first sequence number to be accepted, got %v", err)
+	}
+	g.accept(0xfff0)
+	if err := g.check(0x0005); err != nil {
+		t.Errorf("Expected sequence number after wraparound to be accepted, got %v", err)
+	}
+	g.accept(0x0005)
+	for _, seq := range []uint16{0x0005, 0xfff0, 0xffff} {
+		if err := g.check(seq); err != ErrReplayedPacket {
+			t.Errorf("Expected %#04x to be rejected after wraparound, got %v", seq, err)
+		}
+	}
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	key := testSessionKey(t)
+	plain := testPacket(7, testSubpacket(0x00, testChanInfo("channel")))
+	binary.BigEndian.PutUint16(plain[0:2], 42)
+	sealed := append([]byte(nil), plain...)
+	if err := sealPacket(key, sealed); err != nil {
+		t.Fatal(err)
+	}
+	end := len(plain) - secureChecksumLen
+	if !bytes.Equal(sealed[:secureHeaderLen], plain[:secureHeaderLen]) {
+		t.Error("Expected the header to stay in the clear")
+	}
+	if bytes.Equal(sealed[secureHeaderLen:end], plain[secureHeaderLen:end]) {
+		t.Error("Expected the body to be encrypted")
+	}
+	if err := openPacket(key, sealed); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(sealed[:end], plain[:end]) {
+		t.Error("Expected the opened packet to match the original")
+	}
+	if err := openPacket(key, sealed[:secureHeaderLen]); err != ErrPacketTooShort {
+		t.Errorf("Expected ErrPacketTooShort, got %v", err)
+	}
+}
+
+func TestSecureRejectsBadChecksum(t *testing.T) {
+	i := newSecureTestServer(t)
+	key := testSessionKey(t)
+	i.Keys.Set(testAddr(0).String(), 0, key, 0)
+	i.inject(testAddr(0), sealTestPacket(t, key, 0, testHello(0)))
+	i.wait()
+	client := testClient(i, 0)
+
+	packet := sealTestPacket(t, key, 1, testPacket(0, testSubpacket(0x00, testChanInfo("channel"))))
+	packet[secureHeaderLen] ^= 0xff
+	i.inject(testAddr(0), packet)
+	i.wait()
+	if rejected := client.RejectedPackets(); rejected != 1 {
+		t.Errorf("Expected the tampered packet to be rejected, got %d rejected", rejected)
+	}
+	if dropped := i.DroppedPackets(); dropped != 1 {
+		t.Errorf("Expected the tampered packet to be dropped, got %d dropped", dropped)
+	}
+	if client.State().chanInfo != nil {
+		t.Error("Expected the tampered packet to not be applied")
+	}
+}
+
+func TestSecureHelloWithoutKey(t *testing.T) {
+	i := newSecureTestServer(t)
+	i.inject(testAddr(0), testHello(0))
+	i.wait()
+	if count := i.ClientCount(); count != 0 {
+		t.Errorf("Expected handshake without a session key to be rejected, got %d clients", count)
+	}
+	if dropped := i.DroppedPackets(); dropped != 1 {
+		t.Errorf("Expected the handshake to be dropped, got %d dropped", dropped)
+	}
+}
+
+func TestSessionKeyExpiry(t *testing.T) {
+	store := NewSessionKeyStore()
+	key := testSessionKey(t)
+	addr := testAddr(0)
+	store.Set(addr.String(), 0, key, time.Millisecond)
+	store.Set("", 5, key, 0)
+	time.Sleep(5 * time.Millisecond)
+	if entry, ok := store.Lookup(addr, sealTestPacket(t, key, 0, testHello(0))); !ok || entry.ID != "persona:5" {
+		t.Errorf("Expected the expired address key to be skipped in favour of the persona key, got %+v", entry)
+	}
+	if n := store.Len(); n != 2 {
+		t.Errorf("Expected expired keys to be kept until pruned, got %d keys", n)
+	}
+	store.Prune()
+	if n := store.Len(); n != 1 {
+		t.Errorf("Expected the expired key to be pruned, got %d keys", n)
+	}
+}
+
+func TestRevokeSessionKey(t *testing.T) {
+	i := newSecureTestServer(t)
+	key := testSessionKey(t)
+	entry := i.Keys.Set(testAddr(0).String(), 0, key, 0)
+	i.inject(testAddr(0), sealTestPacket(t, key, 0, testHello(0)))
+	i.wait()
+	if count := i.ClientCount(); count != 1 {
+		t.Fatalf("Expected the client to connect, got %d clients", count)
+	}
+	if !i.RevokeSessionKey(entry.ID) {
+		t.Error("Expected the key to be revoked")
+	}
+	if count := i.ClientCount(); count != 0 {
+		t.Errorf("Expected the client using the key to be kicked, got %d clients", count)
+	}
+	if i.RevokeSessionKey(entry.ID) {
+		t.Error("Expected revoking an unknown key to fail")
+	}
+}
@@ -6,35 +6,141 @@ package freeroam
 
 import (
 	"bytes"
-	"encoding/binary"
+	"context"
+	"errors"
+	"hash/fnv"
 	"log"
 	"net"
+	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
+// NewServer creates a server from config, which should have been checked with Config.Validate.
+// If it is invalid anyway, anomaly detection is disabled rather than applying unknown actions.
 func NewServer(config Config) *Server {
-	return &Server{
-		Clients: make(map[string]*Client),
-		recvbuf: make([]byte, 1024),
+	if err := config.Validate(); err != nil {
+		log.Printf("Disabling anomaly detection: %v", err)
+		config.Anomaly.Enabled = false
+	}
+	workers := config.UDP.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	i := &Server{
 		buffers: &sync.Pool{
 			New: func() interface{} { return new(bytes.Buffer) },
 		},
-		config: config,
+		packetBuffers: &sync.Pool{
+			New: func() interface{} {
+				buf := make([]byte, 1024)
+				return &buf
+			},
+		},
+		config:         config,
+		Keys:           NewSessionKeyStore(),
+		hellos:         newHandshakeLimiter(),
+		spectators:     make(map[int]Spectator),
+		slotCapacities: make(map[int]int),
+		groups:         make(map[string][]int),
+		blocks:         newBlockList(),
+		closing:        make(chan struct{}),
+		stopped:        make(chan struct{}),
+	}
+	allowlist, err := NewPersonaAllowlist(config.Allowlist)
+	if err != nil {
+		log.Printf("Ignoring invalid allowlist entries: %v", err)
+	}
+	i.Allowlist = allowlist
+	bans, err := NewBanList(config.Bans.File)
+	if err != nil {
+		log.Printf("Failed to load bans: %v", err)
+	}
+	i.Bans = bans
+	i.groupIndex.Store(&groupIndex{})
+	i.zones = append([]ZoneConfig(nil), config.Zones...)
+	i.instances = newInstanceRegistry(config.Instances)
+	for _, spectator := range config.Spectators {
+		i.spectators[spectator.PersonaID] = spectator
 	}
+	for _, capacity := range config.SlotCapacities {
+		if capacity.MaxVisiblePlayers < 1 || capacity.MaxVisiblePlayers > MaxSlotCapacity {
+			log.Printf("Ignoring slot capacity of persona %v: %v", capacity.PersonaID, ErrInvalidSlotCapacity)
+			continue
+		}
+		i.slotCapacities[capacity.PersonaID] = capacity.MaxVisiblePlayers
+	}
+	i.shards = make([]*shard, workers)
+	for n := range i.shards {
+		i.shards[n] = newShard(i)
+	}
+	i.buildSnapshot()
+	return i
 }
 
 type Server struct {
-	sync.Mutex
-	listener *net.UDPConn
-	Clients  map[string]*Client
-	recvbuf  []byte
-	buffers  *sync.Pool
-	config   Config
+	listener      *net.UDPConn
+	shards        []*shard
+	snapshot      atomic.Pointer[worldSnapshot]
+	buffers       *sync.Pool
+	packetBuffers *sync.Pool
+	configLock    sync.RWMutex
+	config        Config
+	// Keys holds the session keys used when secure mode is enabled.
+	Keys *SessionKeyStore
+	// Allowlist restricts which personas may join; it should be changed through
+	// the Server methods so that clients which are no longer allowed get kicked.
+	Allowlist *PersonaAllowlist
+	// Bans keeps personas and networks off the server; it should be changed through
+	// the Server methods so that banned clients get kicked.
+	Bans *BanList
+
+	hellos           *handshakeLimiter
+	spectatorLock    sync.RWMutex
+	spectators       map[int]Spectator
+	slotCapacityLock sync.RWMutex
+	slotCapacities   map[int]int
+	groupLock        sync.Mutex
+	groups           map[string][]int
+	groupIndex       atomic.Pointer[groupIndex]
+	blocks           *blockList
+	zones            []ZoneConfig
+	instanceLock     sync.Mutex
+	instances        *instanceRegistry
+	anomalyHandlers  []func(AnomalyEvent)
+	clientCount      atomic.Int64
+
+	droppedPackets     atomic.Uint64
+	overflowPackets    atomic.Uint64
+	malformedPackets   atomic.Uint64
+	rejectedHandshakes atomic.Uint64
+
+	running   atomic.Bool
+	closeOnce sync.Once
+	closing   chan struct{}
+	stopped   chan struct{}
 }
 
+var ErrServerRunning = errors.New("server is already running")
+
+// Listen serves on addrStr until the server is shut down.
 func (i *Server) Listen(addrStr string) error {
-	addr, err := net.ResolveUDPAddr("udp", addrStr)
+	i.configLock.Lock()
+	i.config.UDP.ListenAddress = addrStr
+	i.configLock.Unlock()
+	return i.Serve(context.Background())
+}
+
+// Serve listens on the configured UDP address and runs the server until ctx is
+// cancelled or Shutdown is called. All clients are removed before Serve returns.
+func (i *Server) Serve(ctx context.Context) error {
+	if !i.running.CompareAndSwap(false, true) {
+		return ErrServerRunning
+	}
+	defer close(i.stopped)
+
+	addr, err := net.ResolveUDPAddr("udp", i.udpConfig().ListenAddress)
 	if err != nil {
 		return err
 	}
@@ -42,79 +148,367 @@ func (i *Server) Listen(addrStr string) error {
 	if err != nil {
 		return err
 	}
-	go i.RunTimer()
-	i.RunPacketRead()
-	return nil
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	for _, s := range i.shards {
+		s.start(ctx)
+	}
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		i.RunTimer(ctx)
+	}()
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-i.closing:
+		}
+		i.listener.Close()
+	}()
+
+	err = i.RunPacketRead()
+	cancel()
+	wg.Wait()
+	for _, s := range i.shards {
+		<-s.done
+	}
+	return err
 }
 
-func (i *Server) RunPacketRead() {
+// Shutdown stops reading packets, removes all clients and waits for Serve to return.
+// If ctx expires first, its error is returned.
+func (i *Server) Shutdown(ctx context.Context) error {
+	i.closeOnce.Do(func() {
+		close(i.closing)
+	})
+	if !i.running.Load() {
+		return nil
+	}
+	select {
+	case <-i.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// RunPacketRead reads packets from the socket and hands them to the shard that owns the sender.
+// It returns nil once the socket is closed. Other read errors, such as the connection
+// resets Windows reports after sending to a closed port, are logged and skipped.
+func (i *Server) RunPacketRead() error {
 	for {
-		addr, data := i.readPacket()
-		i.Lock()
-		if len(data) == 58 && data[2] == 0x06 {
-			log.Printf("New client from %v", addr.String())
-			client := newClient(ClientConfig{
-				InitialTick:        binary.BigEndian.Uint16(data[52:54]),
-				Addr:               addr,
-				Conn:               i.listener,
-				Buffers:            i.buffers,
-				Clients:            i.Clients,
-				VisibilityRadius:   i.config.UDP.VisibilityRadius,
-				MaxVisiblePlayers:  i.config.UDP.MaxVisiblePlayers,
-				PlayerSpawnDelayMs: i.config.UDP.PlayerSpawnDelayMs,
-				DisableRadiusSync:  i.config.UDP.DisableRadiusSync,
-			})
-			i.Clients[addr.String()] = client
-			client.replyHandshake()
-			i.Unlock()
+		buf := i.packetBuffers.Get().(*[]byte)
+		n, addr, err := i.listener.ReadFromUDP(*buf)
+		if err != nil {
+			i.packetBuffers.Put(buf)
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			log.Printf("Error reading packet: %v", err)
 			continue
 		}
-		client, ok := i.Clients[addr.String()]
-		if ok {
-			client.processPacket(data)
+		if isHello((*buf)[:n]) && !i.hellos.allow(addr, time.Now(), i.handshakeConfig()) {
+			i.packetBuffers.Put(buf)
+			i.rejectedHandshakes.Add(1)
+			continue
 		}
-		i.Unlock()
+		i.dispatch(addr, buf, n)
+	}
+}
+
+// dispatch queues a packet on its shard. Packets are dropped if the shard can't keep up.
+func (i *Server) dispatch(addr *net.UDPAddr, buf *[]byte, n int) {
+	select {
+	case i.shardFor(addr).events <- shardEvent{addr: addr, buf: buf, n: n}:
+	default:
+		i.packetBuffers.Put(buf)
+		i.overflowPackets.Add(1)
+	}
+}
+
+func (i *Server) shardFor(addr *net.UDPAddr) *shard {
+	h := fnv.New32a()
+	// Addresses read from a socket bound to an IPv4 address have 4-byte IPs, while
+	// resolved ones have 16-byte IPs; both have to end up on the same shard.
+	if ip := addr.IP.To16(); ip != nil {
+		h.Write(ip)
+	} else {
+		h.Write(addr.IP)
 	}
+	h.Write([]byte{byte(addr.Port >> 8), byte(addr.Port)})
+	return i.shards[h.Sum32()%uint32(len(i.shards))]
 }
 
-func (i *Server) RunTimer() {
+// RunTimer drives the server tick. Every tick the position snapshot is rebuilt
+// and all shards are told to send slot packets to their clients.
+func (i *Server) RunTimer(ctx context.Context) {
+	tickTicker := time.NewTicker(tickInterval(i.udpConfig().TickRate))
+	defer tickTicker.Stop()
+	pruneTicker := time.NewTicker(1 * time.Second)
+	defer pruneTicker.Stop()
 	for {
-		i.Lock()
-		for k, client := range i.Clients {
-			if !client.Active() {
-				log.Printf("Removing inactive client %v", client.Addr.String())
-				client.Cleanup()
-				delete(i.Clients, k)
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-tickTicker.C:
+			i.tick(now)
+		case <-pruneTicker.C:
+			i.Keys.Prune()
+			i.ReloadBans()
+		}
+	}
+}
+
+func (i *Server) tick(now time.Time) {
+	i.buildSnapshot()
+	for _, s := range i.shards {
+		// A shard that is still busy with the previous tick skips this one.
+		select {
+		case s.ticks <- now:
+		default:
+		}
+	}
+}
+
+// tickInterval converts a tick rate in Hz to the time between two ticks.
+func tickInterval(rate int) time.Duration {
+	if rate <= 0 {
+		rate = DefaultConfig().UDP.TickRate
+	}
+	return time.Second / time.Duration(rate)
+}
+
+// buildSnapshot rebuilds the position snapshot from the players published by every shard.
+func (i *Server) buildSnapshot() {
+	config := i.udpConfig()
+	cellSize := config.VisibilityRadius
+	if cellSize <= 0 {
+		cellSize = DefaultConfig().UDP.VisibilityRadius
+	}
+	members := make([][]*Client, len(i.shards))
+	for n, s := range i.shards {
+		members[n] = *s.members.Load()
+	}
+	i.snapshot.Store(newWorldSnapshot(cellSize, config.VerticalWeight, members))
+}
+
+func (i *Server) currentSnapshot() *worldSnapshot {
+	return i.snapshot.Load()
+}
+
+func (i *Server) udpConfig() UDPConfig {
+	i.configLock.RLock()
+	defer i.configLock.RUnlock()
+	return i.config.UDP
+}
+
+func (i *Server) handshakeConfig() HandshakeConfig {
+	i.configLock.RLock()
+	defer i.configLock.RUnlock()
+	return i.config.Handshake
+}
+
+// forEachClient runs fn for every client on the goroutine of the shard owning it,
+// and waits until all shards are done. It returns false if any shard isn't running.
+func (i *Server) forEachClient(fn func(client *Client)) bool {
+	var wg sync.WaitGroup
+	var stopped atomic.Bool
+	for _, s := range i.shards {
+		wg.Add(1)
+		go func(s *shard) {
+			defer wg.Done()
+			ran := s.exec(func() {
+				for _, client := range s.clients {
+					fn(client)
+				}
+			})
+			if !ran {
+				stopped.Store(true)
 			}
+		}(s)
+	}
+	wg.Wait()
+	return !stopped.Load()
+}
+
+// execClient runs fn for the client at addr on the goroutine of the shard owning it.
+// It returns false if there is no such client or its shard isn't running.
+func (i *Server) execClient(addr string, fn func(client *Client)) bool {
+	udpAddr, err := net.ResolveUDPAddr("udp", addr)
+	if err != nil {
+		return false
+	}
+	s := i.shardFor(udpAddr)
+	var found bool
+	s.exec(func() {
+		client, ok := s.clients[udpAddr.String()]
+		if ok {
+			fn(client)
+			found = true
 		}
-		i.Unlock()
-		time.Sleep(1 * time.Second)
+	})
+	return found
+}
+
+// Players returns the state of every ready player as of the last snapshot.
+func (i *Server) Players() []*PlayerState {
+	return i.currentSnapshot().players
+}
+
+// ClientCount returns the number of connected clients, including those that aren't ready yet.
+func (i *Server) ClientCount() int {
+	return int(i.clientCount.Load())
+}
+
+// reserveClient claims a place for a new client, failing if the server is full.
+func (i *Server) reserveClient() bool {
+	max := int64(i.handshakeConfig().MaxClients)
+	if i.clientCount.Add(1) > max && max > 0 {
+		i.clientCount.Add(-1)
+		return false
 	}
+	return true
 }
 
-func (i *Server) readPacket() (*net.UDPAddr, []byte) {
-	recvlen, addr, _ := i.listener.ReadFromUDP(i.recvbuf)
-	return addr, i.recvbuf[:recvlen]
+// DroppedPackets returns the number of packets that were dropped because they failed verification.
+func (i *Server) DroppedPackets() uint64 {
+	return i.droppedPackets.Load()
 }
 
-func (i *Server) SetPlayerSpawnDelayForAllClients(delayMs int) {
-	i.Lock()
-	defer i.Unlock()
-	
-	for _, client := range i.Clients {
-		client.SetPlayerSpawnDelay(delayMs)
+// RejectedHandshakes returns the number of handshakes that were rejected by rate limits,
+// the client limit or because they would have replaced an active session.
+func (i *Server) RejectedHandshakes() uint64 {
+	return i.rejectedHandshakes.Load()
+}
+
+// MalformedPackets returns the number of packets that were dropped because they couldn't be parsed.
+func (i *Server) MalformedPackets() uint64 {
+	return i.malformedPackets.Load()
+}
+
+// OverflowPackets returns the number of packets that were dropped because a shard's queue was full.
+func (i *Server) OverflowPackets() uint64 {
+	return i.overflowPackets.Load()
+}
+
+// RevokeSessionKey removes a session key and disconnects all clients that are using it.
+func (i *Server) RevokeSessionKey(id string) bool {
+	removed := i.Keys.Remove(id)
+	var kicked atomic.Bool
+	i.forEachClient(func(client *Client) {
+		if client.sessionKey != nil && client.sessionKey.ID == id {
+			log.Printf("Kicking %v; session key revoked", client.Addr.String())
+			client.detach()
+			kicked.Store(true)
+		}
+	})
+	return removed || kicked.Load()
+}
+
+// SetAllowedPersonas replaces the allowlist for ip, or the global allowlist if ip is empty,
+// and kicks all clients that are no longer allowed.
+func (i *Server) SetAllowedPersonas(ip string, personas []int) error {
+	if err := i.Allowlist.Set(ip, personas); err != nil {
+		return err
+	}
+	i.enforceAllowlist()
+	return nil
+}
+
+// AllowPersonas adds personas to the allowlist for ip, or to the global allowlist if ip is empty.
+// Adding the first persona to a list kicks all clients that are not allowed by it.
+func (i *Server) AllowPersonas(ip string, personas ...int) error {
+	if err := i.Allowlist.Add(ip, personas...); err != nil {
+		return err
+	}
+	i.enforceAllowlist()
+	return nil
+}
+
+// DisallowPersonas removes personas from the allowlist for ip, or from the global allowlist
+// if ip is empty, and kicks all clients that are no longer allowed.
+func (i *Server) DisallowPersonas(ip string, personas ...int) error {
+	if err := i.Allowlist.Remove(ip, personas...); err != nil {
+		return err
+	}
+	i.enforceAllowlist()
+	return nil
+}
+
+// enforceAllowlist kicks clients whose persona is not allowed by the current allowlist.
+func (i *Server) enforceAllowlist() {
+	i.forEachClient(func(client *Client) {
+		if client.personaID != 0 && !i.Allowlist.Allowed(client.Addr.IP, client.personaID) {
+			log.Printf("Kicking %v; persona %v is no longer allowed", client.Addr.String(), client.personaID)
+			client.detach()
+		}
+	})
+}
+
+// Ban adds a ban and kicks all clients it applies to.
+func (i *Server) Ban(ban Ban) (Ban, error) {
+	ban, err := i.Bans.Add(ban)
+	if ban.ID != "" {
+		// The ban is in effect even if it couldn't be saved.
+		i.enforceBans()
+	}
+	return ban, err
+}
+
+// Unban lifts the ban with the given ID. It returns false if there was no such ban.
+func (i *Server) Unban(id string) (bool, error) {
+	return i.Bans.Remove(id)
+}
+
+// ReloadBans rereads the ban file if it changed and kicks all clients that are banned now.
+func (i *Server) ReloadBans() {
+	reloaded, err := i.Bans.Reload()
+	if err != nil {
+		log.Printf("Failed to reload bans: %v", err)
+		return
 	}
-	
+	if reloaded {
+		log.Print("Reloaded bans")
+		i.enforceBans()
+	}
+}
+
+// enforceBans kicks clients that are covered by a ban.
+func (i *Server) enforceBans() {
+	i.forEachClient(func(client *Client) {
+		if ban, ok := i.Bans.Check(client.Addr.IP, client.personaID); ok {
+			log.Printf("Kicking %v; %v is banned: %v", client.Addr.String(), ban.ID, ban.Reason)
+			client.detach()
+		}
+	})
+}
+
+// SetClientTickRate overrides the rate at which slot packets are sent to the client at addr.
+// The rate can't exceed the server tick rate; a rate of 0 restores the server tick rate.
+func (i *Server) SetClientTickRate(addr string, rate int) bool {
+	return i.execClient(addr, func(client *Client) {
+		client.SetTickRate(rate)
+	})
+}
+
+func (i *Server) SetPlayerSpawnDelayForAllClients(delayMs int) {
+	i.configLock.Lock()
 	i.config.UDP.PlayerSpawnDelayMs = delayMs
+	i.configLock.Unlock()
+
+	i.forEachClient(func(client *Client) {
+		client.SetPlayerSpawnDelay(delayMs)
+	})
 }
 
 func (i *Server) SetRadiusSyncForAllClients(enabled bool) {
-	i.Lock()
-	defer i.Unlock()
-	
-	for _, client := range i.Clients {
-		client.SetRadiusSync(enabled)
-	}
-	
+	i.configLock.Lock()
 	i.config.UDP.DisableRadiusSync = !enabled
+	i.configLock.Unlock()
+
+	i.forEachClient(func(client *Client) {
+		client.SetRadiusSync(enabled)
+	})
 }
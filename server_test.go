@@ -0,0 +1,356 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"math/rand"
+	"net"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/WorldUnitedNFS/freeroam/math"
+)
+
+func TestMain(m *testing.M) {
+	log.SetOutput(io.Discard)
+	os.Exit(m.Run())
+}
+
+func testHello(tick uint16) []byte {
+	hello := make([]byte, 58)
+	hello[2] = 0x06
+	binary.BigEndian.PutUint16(hello[52:54], tick)
+	return hello
+}
+
+func testChanInfo(channel string) []byte {
+	info := make([]byte, 34)
+	copy(info[2:], channel)
+	return info
+}
+
+func testPlayerInfo(name string, personaID uint32) []byte {
+	info := make([]byte, 64)
+	copy(info[1:33], name)
+	binary.LittleEndian.PutUint32(info[41:45], personaID)
+	return info
+}
+
+func testPacket(srvCounter uint16, subpackets ...[]byte) []byte {
+	buf := new(bytes.Buffer)
+	buf.Write(make([]byte, 16))
+	for _, sp := range subpackets {
+		buf.Write(sp)
+	}
+	buf.Write([]byte{0xff, 0x00, 0x00, 0x00, 0x00})
+	packet := buf.Bytes()
+	binary.BigEndian.PutUint16(packet[8:10], srvCounter)
+	return packet
+}
+
+func testSubpacket(typ uint8, data []byte) []byte {
+	buf := new(bytes.Buffer)
+	WriteSubpacket(buf, typ, data)
+	return buf.Bytes()
+}
+
+func testCarPos(rng *rand.Rand) []byte {
+	pos := make([]byte, 24)
+	rng.Read(pos)
+	return pos
+}
+
+func newTestServer(tb testing.TB, workers int) *Server {
+	config := DefaultConfig()
+	config.UDP.Workers = workers
+	return newTestServerWithConfig(tb, config)
+}
+
+// instantSlotsConfig returns a config for workers shards without the exit radius and
+// minimum slot time, so that slots follow players as soon as they move.
+func instantSlotsConfig(workers int) Config {
+	config := DefaultConfig()
+	config.UDP.Workers = workers
+	config.UDP.VisibilityExitRadius = config.UDP.VisibilityRadius
+	config.UDP.MinSlotTimeMs = 0
+	return config
+}
+
+func newTestServerWithConfig(tb testing.TB, config Config) *Server {
+	i := NewServer(config)
+	conn, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { conn.Close() })
+	i.listener = conn
+	ctx, cancel := context.WithCancel(context.Background())
+	tb.Cleanup(cancel)
+	for _, s := range i.shards {
+		s.start(ctx)
+	}
+	return i
+}
+
+// inject queues a packet on its shard, blocking instead of dropping it if the queue is full.
+func (i *Server) inject(addr *net.UDPAddr, data []byte) {
+	buf := i.packetBuffers.Get().(*[]byte)
+	n := copy(*buf, data)
+	i.shardFor(addr).events <- shardEvent{addr: addr, buf: buf, n: n}
+}
+
+// wait blocks until every shard has processed all packets queued before the call.
+// Shards that haven't been started yet are waited for.
+func (i *Server) wait() {
+	for !i.forEachClient(func(*Client) {}) {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func testAddr(n int) *net.UDPAddr {
+	return &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 20000 + n}
+}
+
+func testClient(i *Server, n int) *Client {
+	var out *Client
+	i.execClient(testAddr(n).String(), func(client *Client) {
+		out = client
+	})
+	return out
+}
+
+// visibleTo recalculates the slots of the client at testAddr(n), spawns all pending
+// players and returns the players in its slots.
+func visibleTo(i *Server, n int) map[*Client]bool {
+	i.buildSnapshot()
+	out := make(map[*Client]bool)
+	i.execClient(testAddr(n).String(), func(client *Client) {
+		client.recalculateSlots()
+		for range client.slots {
+			client.processNextPendingPlayer()
+		}
+		for _, slot := range client.slots {
+			if slot != nil {
+				out[slot.Client] = true
+			}
+		}
+	})
+	return out
+}
+
+// place moves the client at testAddr(n) to x, y on the ground.
+func place(i *Server, n int, x, y float64) {
+	placeAt(i, n, math.Vector3D{X: x, Y: y})
+}
+
+func placeAt(i *Server, n int, coords math.Vector3D) {
+	i.execClient(testAddr(n).String(), func(client *Client) {
+		client.carPos.pos = math.Vector2D{X: coords.X, Y: coords.Y}
+		client.carPos.coords = coords
+		client.updateZone()
+		client.publish()
+	})
+}
+
+func populate(i *Server, count int, rng *rand.Rand) {
+	for n := 0; n < count; n++ {
+		i.inject(testAddr(n), testHello(uint16(n)))
+	}
+	i.wait()
+	for n := 0; n < count; n++ {
+		i.inject(testAddr(n), testPacket(0,
+			testSubpacket(0x00, testChanInfo("channel")),
+			testSubpacket(0x01, testPlayerInfo(fmt.Sprintf("player%d", n), uint32(n+1))),
+			testSubpacket(0x12, testCarPos(rng)),
+		))
+	}
+	i.wait()
+	i.buildSnapshot()
+}
+
+func TestServerShards(t *testing.T) {
+	i := newTestServer(t, 4)
+	populate(i, 100, rand.New(rand.NewSource(1)))
+	if count := i.ClientCount(); count != 100 {
+		t.Errorf("Expected 100 clients, got %d", count)
+	}
+	if players := len(i.Players()); players != 100 {
+		t.Errorf("Expected 100 ready players in snapshot, got %d", players)
+	}
+}
+
+func benchmarkPackets(b *testing.B, workers int) {
+	const clients = 1000
+	rng := rand.New(rand.NewSource(1))
+	i := newTestServer(b, workers)
+	populate(i, clients, rng)
+	packets := make([][]byte, clients)
+	for n := range packets {
+		packets[n] = testPacket(0, testSubpacket(0x12, testCarPos(rng)))
+	}
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		i.inject(testAddr(n%clients), packets[n%clients])
+	}
+	i.wait()
+}
+
+func benchmarkTick(b *testing.B, workers int) {
+	const clients = 1000
+	i := newTestServer(b, workers)
+	populate(i, clients, rand.New(rand.NewSource(1)))
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		now := time.Now()
+		i.buildSnapshot()
+		i.forEachClient(func(client *Client) {
+			client.broadcast(now)
+		})
+	}
+}
+
+func BenchmarkTick(b *testing.B) {
+	b.Run("workers=1", func(b *testing.B) {
+		benchmarkTick(b, 1)
+	})
+	b.Run(fmt.Sprintf("workers=%d", runtime.NumCPU()), func(b *testing.B) {
+		benchmarkTick(b, runtime.NumCPU())
+	})
+}
+
+func BenchmarkPacketProcessing(b *testing.B) {
+	b.Run("workers=1", func(b *testing.B) {
+		benchmarkPackets(b, 1)
+	})
+	b.Run(fmt.Sprintf("workers=%d", runtime.NumCPU()), func(b *testing.B) {
+		benchmarkPackets(b, runtime.NumCPU())
+	})
+}
+
+func TestServerShutdown(t *testing.T) {
+	config := DefaultConfig()
+	config.UDP.ListenAddress = "127.0.0.1:0"
+	i := NewServer(config)
+	served := make(chan error, 1)
+	go func() {
+		served <- i.Serve(context.Background())
+	}()
+	populate(i, 10, rand.New(rand.NewSource(1)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := i.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Expected Serve to return nil after shutdown, got %v", err)
+	}
+	if count := i.ClientCount(); count != 0 {
+		t.Errorf("Expected all clients to be removed, got %d", count)
+	}
+	if err := i.Serve(context.Background()); err != ErrServerRunning {
+		t.Errorf("Expected a second Serve to fail, got %v", err)
+	}
+}
+
+func TestShardNotRunning(t *testing.T) {
+	config := DefaultConfig()
+	config.UDP.ListenAddress = "127.0.0.1:0"
+	i := NewServer(config)
+	if i.forEachClient(func(*Client) {}) {
+		t.Error("Expected forEachClient to fail before Serve")
+	}
+	if i.execClient(testAddr(0).String(), func(*Client) {}) {
+		t.Error("Expected execClient to fail before Serve")
+	}
+
+	served := make(chan error, 1)
+	go func() {
+		served <- i.Serve(context.Background())
+	}()
+	populate(i, 1, rand.New(rand.NewSource(1)))
+	if !i.forEachClient(func(*Client) {}) {
+		t.Error("Expected forEachClient to succeed while serving")
+	}
+	if err := i.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	<-served
+	if i.forEachClient(func(*Client) {}) {
+		t.Error("Expected forEachClient to fail after Shutdown")
+	}
+	if i.execClient(testAddr(0).String(), func(*Client) {}) {
+		t.Error("Expected execClient to fail after Shutdown")
+	}
+}
+
+func TestHandshakeHardening(t *testing.T) {
+	config := DefaultConfig()
+	config.UDP.Workers = 2
+	config.Handshake.MaxClients = 5
+	i := newTestServerWithConfig(t, config)
+
+	populate(i, 5, rand.New(rand.NewSource(1)))
+	first := i.shardFor(testAddr(0))
+	var original *Client
+	first.exec(func() { original = first.clients[testAddr(0).String()] })
+
+	i.inject(testAddr(0), testHello(0))
+	i.inject(testAddr(0), testHello(1234))
+	i.wait()
+	var current *Client
+	first.exec(func() { current = first.clients[testAddr(0).String()] })
+	if current != original {
+		t.Error("Expected handshake to not replace an active session")
+	}
+
+	i.inject(testAddr(5), testHello(0))
+	i.wait()
+	if count := i.ClientCount(); count != 5 {
+		t.Errorf("Expected client limit of 5 to be enforced, got %d clients", count)
+	}
+	if rejected := i.RejectedHandshakes(); rejected != 2 {
+		t.Errorf("Expected 2 rejected handshakes, got %d", rejected)
+	}
+}
+
+func TestHandshakeLimiter(t *testing.T) {
+	l := newHandshakeLimiter()
+	config := DefaultConfig().Handshake
+	now := time.Now()
+	allowed := 0
+	for n := 0; n < 20; n++ {
+		if l.allow(testAddr(n), now, config) {
+			allowed++
+		}
+	}
+	if allowed != config.BurstPerIP {
+		t.Errorf("Expected %d handshakes from one IP to be allowed, got %d", config.BurstPerIP, allowed)
+	}
+	if !l.allow(testAddr(0), now.Add(time.Second), config) {
+		t.Error("Expected per-IP bucket to refill")
+	}
+}
+
+func TestShardForIPv4Addr(t *testing.T) {
+	i := newTestServer(t, 8)
+	for n := 0; n < 32; n++ {
+		// ReadFromUDP returns 4-byte IPs on sockets bound to an IPv4 address.
+		addr := &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1).To4(), Port: 21000 + n}
+		i.inject(addr, testHello(uint16(n)))
+		i.wait()
+		if !i.SetClientTickRate(addr.String(), 10) {
+			t.Errorf("Expected client registered with a 4-byte IP to be found by address %v", addr)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"log"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// spawnTickInterval is how often shards check whether clients can spawn their next pending player.
+const spawnTickInterval = 25 * time.Millisecond
+
+// shardEvent is either an inbound packet or a task that has to run on the shard goroutine.
+type shardEvent struct {
+	addr *net.UDPAddr
+	buf  *[]byte
+	n    int
+	task func()
+}
+
+// shard owns a subset of the server's clients. All client state is only ever touched
+// by the shard's goroutine; other clients read it through published PlayerStates.
+type shard struct {
+	server  *Server
+	clients map[string]*Client
+	events  chan shardEvent
+	ticks   chan time.Time
+	done    chan struct{}
+	started atomic.Bool
+	members atomic.Pointer[[]*Client]
+}
+
+func newShard(server *Server) *shard {
+	s := &shard{
+		server:  server,
+		clients: make(map[string]*Client),
+		events:  make(chan shardEvent, 1024),
+		ticks:   make(chan time.Time, 1),
+		done:    make(chan struct{}),
+	}
+	s.publishMembers()
+	return s
+}
+
+// start runs the shard on a new goroutine until ctx is cancelled. done is closed once
+// the goroutine has exited.
+func (s *shard) start(ctx context.Context) {
+	s.started.Store(true)
+	go s.run(ctx)
+}
+
+// run processes events until ctx is cancelled, then removes all clients.
+func (s *shard) run(ctx context.Context) {
+	defer close(s.done)
+	defer s.drain()
+
+	spawnTicker := time.NewTicker(spawnTickInterval)
+	defer spawnTicker.Stop()
+	cleanupTicker := time.NewTicker(1 * time.Second)
+	defer cleanupTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case ev := <-s.events:
+			if ev.task != nil {
+				ev.task()
+				continue
+			}
+			s.handlePacket(ev.addr, (*ev.buf)[:ev.n])
+			s.server.packetBuffers.Put(ev.buf)
+		case now := <-s.ticks:
+			for _, client := range s.clients {
+				client.broadcast(now)
+			}
+		case now := <-spawnTicker.C:
+			for _, client := range s.clients {
+				client.processPendingPlayers(now)
+			}
+		case <-cleanupTicker.C:
+			for _, client := range s.clients {
+				if !client.Active() {
+					log.Printf("Removing inactive client %v", client.Addr.String())
+					s.removeClient(client)
+				}
+			}
+		}
+	}
+}
+
+// drain removes all clients of the shard.
+func (s *shard) drain() {
+	for _, client := range s.clients {
+		s.removeClient(client)
+	}
+}
+
+// exec runs fn on the shard goroutine and waits for it to complete. It returns false
+// without running fn if the shard hasn't been started or has stopped.
+// exec must not be called from a shard goroutine.
+func (s *shard) exec(fn func()) bool {
+	if !s.started.Load() {
+		return false
+	}
+	done := make(chan struct{})
+	ev := shardEvent{task: func() {
+		fn()
+		close(done)
+	}}
+	select {
+	case s.events <- ev:
+	case <-s.done:
+		return false
+	}
+	select {
+	case <-done:
+		return true
+	case <-s.done:
+		// The shard may have run fn just before stopping.
+		select {
+		case <-done:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// publishMembers makes the current client list available to the snapshot builder.
+func (s *shard) publishMembers() {
+	members := make([]*Client, 0, len(s.clients))
+	for _, client := range s.clients {
+		members = append(members, client)
+	}
+	s.members.Store(&members)
+}
+
+// isHello returns true if data is a handshake packet.
+func isHello(data []byte) bool {
+	return len(data) == 58 && data[2] == 0x06
+}
+
+func (s *shard) handlePacket(addr *net.UDPAddr, data []byte) {
+	if isHello(data) {
+		s.handleHello(addr, data)
+		return
+	}
+	client, ok := s.clients[addr.String()]
+	if !ok {
+		return
+	}
+	if !client.openPacket(data) {
+		s.server.droppedPackets.Add(1)
+		return
+	}
+	if err := client.processPacket(data); err != nil {
+		client.malformedPackets.Add(1)
+		s.server.malformedPackets.Add(1)
+	}
+}
+
+func (s *shard) handleHello(addr *net.UDPAddr, data []byte) {
+	config := s.server.udpConfig()
+	var sessionKey *SessionKeyEntry
+	hello := data
+	if config.Secure {
+		entry, ok := s.server.Keys.Lookup(addr, data)
+		if !ok {
+			log.Printf("Rejecting handshake from %v: no session key", addr.String())
+			s.server.droppedPackets.Add(1)
+			return
+		}
+		hello = append([]byte(nil), data...)
+		if err := openPacket(entry.Key, hello); err != nil {
+			log.Printf("Rejecting handshake from %v: %v", addr.String(), err)
+			s.server.droppedPackets.Add(1)
+			return
+		}
+		sessionKey = &entry
+	}
+
+	old, exists := s.clients[addr.String()]
+	if exists {
+		if bytes.Equal(old.hello, data) {
+			// A retransmitted handshake; the reply probably got lost.
+			old.replyHandshake()
+			return
+		}
+		grace := time.Duration(s.server.handshakeConfig().ReconnectGraceMs) * time.Millisecond
+		if sessionKey == nil && old.established && time.Since(old.LastPacket) < grace {
+			log.Printf("Rejecting handshake from %v: session is still active", addr.String())
+			s.server.rejectedHandshakes.Add(1)
+			return
+		}
+		log.Printf("Client %v reconnected", addr.String())
+		s.removeClient(old)
+	}
+	if ban, ok := s.server.Bans.CheckIP(addr.IP); ok {
+		log.Printf("Rejecting handshake from %v: %v is banned: %v", addr.String(), ban.ID, ban.Reason)
+		s.server.rejectedHandshakes.Add(1)
+		return
+	}
+	if !s.server.reserveClient() {
+		log.Printf("Rejecting handshake from %v: server is full", addr.String())
+		s.server.rejectedHandshakes.Add(1)
+		return
+	}
+
+	log.Printf("New client from %v", addr.String())
+	client := newClient(ClientConfig{
+		InitialTick:        binary.BigEndian.Uint16(hello[52:54]),
+		Addr:               addr,
+		Conn:               s.server.listener,
+		Buffers:            s.server.buffers,
+		VisibilityRadius:   config.VisibilityRadius,
+		ExitRadius:         config.VisibilityExitRadius,
+		MinSlotTimeMs:      config.MinSlotTimeMs,
+		MaxVisiblePlayers:  config.MaxVisiblePlayers,
+		PlayerSpawnDelayMs: config.PlayerSpawnDelayMs,
+		DisableRadiusSync:  config.DisableRadiusSync,
+		SessionKey:         sessionKey,
+		Allowlist:          s.server.Allowlist,
+		Bans:               s.server.Bans,
+	})
+	client.shard = s
+	client.hello = append([]byte(nil), data...)
+	if sessionKey != nil {
+		client.recvSeqs.accept(binary.BigEndian.Uint16(hello[0:2]))
+	}
+	s.clients[addr.String()] = client
+	s.publishMembers()
+	client.replyHandshake()
+}
+
+func (s *shard) removeClient(client *Client) {
+	client.Cleanup()
+	if s.clients[client.Addr.String()] != client {
+		return
+	}
+	delete(s.clients, client.Addr.String())
+	s.server.clientCount.Add(-1)
+	s.publishMembers()
+}
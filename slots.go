@@ -0,0 +1,111 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"cmp"
+	"errors"
+	"slices"
+)
+
+// MaxSlotCapacity is the largest number of slots a client can have. It keeps slot packets
+// well within a single datagram.
+const MaxSlotCapacity = 32
+
+var ErrInvalidSlotCapacity = errors.New("slot capacity must be between 1 and 32")
+
+// SlotCapacity overrides UDPConfig.MaxVisiblePlayers for a persona, e.g. to give
+// low-end PCs fewer slots or streamers more.
+type SlotCapacity struct {
+	PersonaID         int `json:"personaId"`
+	MaxVisiblePlayers int `json:"maxVisiblePlayers"`
+}
+
+// SetSlotCapacity sets the number of slots of a persona, replacing its previous setting.
+// Connected clients of the persona are resized right away.
+func (i *Server) SetSlotCapacity(capacity SlotCapacity) error {
+	if capacity.MaxVisiblePlayers < 1 || capacity.MaxVisiblePlayers > MaxSlotCapacity {
+		return ErrInvalidSlotCapacity
+	}
+	i.slotCapacityLock.Lock()
+	i.slotCapacities[capacity.PersonaID] = capacity.MaxVisiblePlayers
+	i.slotCapacityLock.Unlock()
+	i.refreshSlotCapacities()
+	return nil
+}
+
+// RemoveSlotCapacity gives a persona the server-wide number of slots again.
+// It returns false if the persona had no slot capacity of its own.
+func (i *Server) RemoveSlotCapacity(personaID int) bool {
+	i.slotCapacityLock.Lock()
+	_, ok := i.slotCapacities[personaID]
+	delete(i.slotCapacities, personaID)
+	i.slotCapacityLock.Unlock()
+	i.refreshSlotCapacities()
+	return ok
+}
+
+// SlotCapacities returns all per-persona slot capacities, ordered by persona ID.
+func (i *Server) SlotCapacities() []SlotCapacity {
+	i.slotCapacityLock.RLock()
+	defer i.slotCapacityLock.RUnlock()
+	out := make([]SlotCapacity, 0, len(i.slotCapacities))
+	for personaID, capacity := range i.slotCapacities {
+		out = append(out, SlotCapacity{PersonaID: personaID, MaxVisiblePlayers: capacity})
+	}
+	slices.SortFunc(out, func(a, b SlotCapacity) int {
+		return cmp.Compare(a.PersonaID, b.PersonaID)
+	})
+	return out
+}
+
+// slotCapacity returns the number of slots of a persona, or 0 if it uses the server-wide setting.
+func (i *Server) slotCapacity(personaID int) int {
+	i.slotCapacityLock.RLock()
+	defer i.slotCapacityLock.RUnlock()
+	return i.slotCapacities[personaID]
+}
+
+// refreshSlotCapacities applies the current slot capacities to all clients.
+func (i *Server) refreshSlotCapacities() {
+	i.forEachClient(func(client *Client) {
+		client.updateSlotCapacity()
+	})
+}
+
+// updateSlotCapacity looks up the client's slot capacity by its persona.
+func (c *Client) updateSlotCapacity() {
+	if c.personaID == 0 {
+		return
+	}
+	c.resizeSlots(c.shard.server.slotCapacity(c.personaID))
+}
+
+// resizeSlots changes the number of slots of the client; 0 restores the server-wide number.
+// Slots never change their index: new slots are added at the end, and players in slots
+// beyond the new capacity lose them and wait for a free slot like newly added players.
+// The emptied slots are sent as empty once more before they are dropped by trimSlots.
+func (c *Client) resizeSlots(capacity int) {
+	if capacity <= 0 {
+		capacity = c.defaultSlotCapacity
+	}
+	if capacity > MaxSlotCapacity {
+		capacity = MaxSlotCapacity
+	}
+	c.slotCapacity = capacity
+	for len(c.slots) < capacity {
+		c.slots = append(c.slots, nil)
+	}
+	for index := capacity; index < len(c.slots); index++ {
+		c.slots[index] = nil
+	}
+}
+
+// trimSlots drops the slots beyond the client's capacity after they have been sent as empty.
+func (c *Client) trimSlots() {
+	if len(c.slots) > c.slotCapacity {
+		c.slots = c.slots[:c.slotCapacity]
+	}
+}
@@ -0,0 +1,84 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"math/rand"
+	"testing"
+)
+
+// slotClients returns the players in each of the slots of client n.
+func slotClients(i *Server, n int) []*Client {
+	var out []*Client
+	i.execClient(testAddr(n).String(), func(client *Client) {
+		out = make([]*Client, len(client.slots))
+		for index, slot := range client.slots {
+			if slot != nil {
+				out[index] = slot.Client
+			}
+		}
+	})
+	return out
+}
+
+func TestSlotCapacity(t *testing.T) {
+	config := instantSlotsConfig(2)
+	config.UDP.MaxVisiblePlayers = 3
+	i := newTestServerWithConfig(t, config)
+	populate(i, 6, rand.New(rand.NewSource(1)))
+	for n := 0; n < 6; n++ {
+		place(i, n, float64(n*10), 0)
+	}
+	visibleTo(i, 0)
+	before := slotClients(i, 0)
+	if len(before) != 3 {
+		t.Fatalf("Expected 3 slots, got %d", len(before))
+	}
+
+	if err := i.SetSlotCapacity(SlotCapacity{PersonaID: 1, MaxVisiblePlayers: MaxSlotCapacity + 1}); err != ErrInvalidSlotCapacity {
+		t.Errorf("Expected ErrInvalidSlotCapacity, got %v", err)
+	}
+	if err := i.SetSlotCapacity(SlotCapacity{PersonaID: 1, MaxVisiblePlayers: 5}); err != nil {
+		t.Fatal(err)
+	}
+	if visible := visibleTo(i, 0); len(visible) != 5 {
+		t.Errorf("Expected 5 visible players after growing, got %d", len(visible))
+	}
+	grown := slotClients(i, 0)
+	for index, client := range before {
+		if grown[index] != client {
+			t.Errorf("Expected slot %d to keep its player after growing", index)
+		}
+	}
+
+	if err := i.SetSlotCapacity(SlotCapacity{PersonaID: 1, MaxVisiblePlayers: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if visible := visibleTo(i, 0); len(visible) != 2 {
+		t.Errorf("Expected 2 visible players after shrinking, got %d", len(visible))
+	}
+	shrunk := slotClients(i, 0)
+	if len(shrunk) != 5 || shrunk[2] != nil || shrunk[3] != nil || shrunk[4] != nil {
+		t.Errorf("Expected the slots beyond the capacity to be empty until they are sent, got %v", shrunk)
+	}
+	for index := 0; index < 2; index++ {
+		if shrunk[index] != before[index] {
+			t.Errorf("Expected slot %d to keep its player after shrinking", index)
+		}
+	}
+	i.execClient(testAddr(0).String(), func(client *Client) {
+		client.trimSlots()
+	})
+	if slots := slotClients(i, 0); len(slots) != 2 {
+		t.Errorf("Expected 2 slots after trimming, got %d", len(slots))
+	}
+
+	if !i.RemoveSlotCapacity(1) {
+		t.Error("Expected slot capacity to be removed")
+	}
+	if visible := visibleTo(i, 0); len(visible) != 3 {
+		t.Errorf("Expected the server-wide 3 slots again, got %d visible players", len(visible))
+	}
+}
@@ -0,0 +1,97 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/WorldUnitedNFS/freeroam/math"
+	"github.com/WorldUnitedNFS/freeroam/spatial"
+)
+
+// PlayerState is an immutable copy of the data a client broadcasts to other clients.
+// A client publishes a new PlayerState whenever its data changes, so that the state
+// can be read from any goroutine without locking.
+type PlayerState struct {
+	Client      *Client
+	PersonaName string
+	PersonaID   int
+	Player      PlayerInfo
+	Channel     ChannelInfo
+	ChannelName string
+	Pos         math.Vector2D
+	Coords      math.Vector3D
+	Zone        string
+	Instance    string
+	Rotation    float64
+	Net         NetStats
+
+	carPos     []byte
+	chanInfo   []byte
+	playerInfo []byte
+	posRecvTD  uint16
+	updateID   uint8
+	// hiddenUntil keeps the player out of other clients' slots until it has passed.
+	hiddenUntil time.Time
+	// ghostUntil keeps the player out of the snapshot entirely until it has passed.
+	ghostUntil time.Time
+	spectator  bool
+}
+
+// Ready returns true if the state contains valid channel info, player info and position data.
+func (p *PlayerState) Ready() bool {
+	return p.chanInfo != nil && p.playerInfo != nil && p.carPos != nil
+}
+
+func (p *PlayerState) writeFullPosPacket(buf *bytes.Buffer) {
+	buf.WriteByte(0x00) // Slot start
+	WriteSubpacket(buf, 0x12, p.carPos)
+	buf.WriteByte(0xff) // Slot end
+}
+
+func (p *PlayerState) writeFullSlotPacket(buf *bytes.Buffer) {
+	buf.WriteByte(0x00) // Slot start
+	WriteSubpacket(buf, 0x00, p.chanInfo)
+	WriteSubpacket(buf, 0x01, p.playerInfo)
+	WriteSubpacket(buf, 0x12, p.carPos)
+	buf.WriteByte(0xff) // Slot end
+}
+
+// worldSnapshot is an immutable view of all ready players.
+// The server rebuilds it at a fixed interval and swaps it in atomically.
+type worldSnapshot struct {
+	built     time.Time
+	index     *spatial.Grid[*Client]
+	players   []*PlayerState
+	byPersona map[int]*PlayerState
+}
+
+func newWorldSnapshot(cellSize, verticalWeight float64, members [][]*Client) *worldSnapshot {
+	snap := &worldSnapshot{
+		built:     time.Now(),
+		index:     spatial.NewGrid[*Client](cellSize),
+		players:   make([]*PlayerState, 0),
+		byPersona: make(map[int]*PlayerState),
+	}
+	snap.index.SetVerticalWeight(verticalWeight)
+	for _, clients := range members {
+		for _, client := range clients {
+			state := client.State()
+			if !state.Ready() || state.spectator || snap.built.Before(state.ghostUntil) {
+				continue
+			}
+			snap.players = append(snap.players, state)
+			if state.PersonaID != 0 {
+				snap.byPersona[state.PersonaID] = state
+			}
+			if snap.built.Before(state.hiddenUntil) {
+				continue
+			}
+			snap.index.Update(client, state.Coords)
+		}
+	}
+	return snap
+}
@@ -0,0 +1,237 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// Package spatial implements a uniform grid index for radius and nearest-neighbour queries.
+// Items are placed in cells by their X/Y position, while distances are measured in 3D
+// with an adjustable weight on the vertical (Z) axis.
+package spatial
+
+import (
+	stdmath "math"
+	"sort"
+
+	"github.com/WorldUnitedNFS/freeroam/math"
+)
+
+type cell struct {
+	X int
+	Y int
+}
+
+type entry struct {
+	cell cell
+	pos  math.Vector3D
+}
+
+// Neighbour is a query result along with its distance from the query center.
+type Neighbour[T comparable] struct {
+	Item     T
+	Pos      math.Vector3D
+	Distance float64
+}
+
+// Grid is a uniform grid of square cells keyed on X/Y positions.
+// A Grid is not safe for concurrent use.
+type Grid[T comparable] struct {
+	cellSize       float64
+	verticalWeight float64
+	cells          map[cell]map[T]math.Vector3D
+	items          map[T]entry
+	min            cell
+	max            cell
+}
+
+// NewGrid creates a grid with the specified cell size. Choosing a cell size close to
+// the usual query radius keeps radius queries down to a handful of cells.
+// Distances are measured in 2D until a vertical weight is set.
+func NewGrid[T comparable](cellSize float64) *Grid[T] {
+	if cellSize <= 0 {
+		cellSize = 1
+	}
+	return &Grid[T]{
+		cellSize: cellSize,
+		cells:    make(map[cell]map[T]math.Vector3D),
+		items:    make(map[T]entry),
+	}
+}
+
+// SetVerticalWeight sets the factor Z differences are multiplied with when measuring
+// distances. A weight of 1 gives the Euclidean distance, and 0 ignores Z entirely.
+// Negative weights are treated as 0.
+func (g *Grid[T]) SetVerticalWeight(weight float64) {
+	if weight < 0 {
+		weight = 0
+	}
+	g.verticalWeight = weight
+}
+
+// Distance returns the distance between two positions as measured by the grid.
+// It is never less than the distance on the X/Y plane, which the cell layout relies on.
+func (g *Grid[T]) Distance(a, b math.Vector3D) float64 {
+	return math.WeightedDistance3D(a, b, g.verticalWeight)
+}
+
+func (g *Grid[T]) cellOf(pos math.Vector3D) cell {
+	return cell{
+		X: int(stdmath.Floor(pos.X / g.cellSize)),
+		Y: int(stdmath.Floor(pos.Y / g.cellSize)),
+	}
+}
+
+// Len returns the number of items in the grid.
+func (g *Grid[T]) Len() int {
+	return len(g.items)
+}
+
+// Update inserts an item or moves it to a new position.
+func (g *Grid[T]) Update(item T, pos math.Vector3D) {
+	c := g.cellOf(pos)
+	if old, ok := g.items[item]; ok {
+		if old.cell == c {
+			g.cells[c][item] = pos
+			g.items[item] = entry{cell: c, pos: pos}
+			return
+		}
+		g.removeFromCell(item, old.cell)
+	}
+	bucket, ok := g.cells[c]
+	if !ok {
+		bucket = make(map[T]math.Vector3D)
+		g.cells[c] = bucket
+	}
+	bucket[item] = pos
+	g.items[item] = entry{cell: c, pos: pos}
+	if len(g.items) == 1 {
+		g.min, g.max = c, c
+	} else {
+		g.min = cell{X: minInt(g.min.X, c.X), Y: minInt(g.min.Y, c.Y)}
+		g.max = cell{X: maxInt(g.max.X, c.X), Y: maxInt(g.max.Y, c.Y)}
+	}
+}
+
+// Remove deletes an item from the grid.
+func (g *Grid[T]) Remove(item T) {
+	old, ok := g.items[item]
+	if !ok {
+		return
+	}
+	g.removeFromCell(item, old.cell)
+	delete(g.items, item)
+}
+
+func (g *Grid[T]) removeFromCell(item T, c cell) {
+	bucket := g.cells[c]
+	delete(bucket, item)
+	if len(bucket) == 0 {
+		delete(g.cells, c)
+	}
+}
+
+// Pos returns the indexed position of an item.
+func (g *Grid[T]) Pos(item T) (math.Vector3D, bool) {
+	e, ok := g.items[item]
+	return e.pos, ok
+}
+
+// Each calls fn for every item in the grid.
+func (g *Grid[T]) Each(fn func(item T, pos math.Vector3D)) {
+	for item, e := range g.items {
+		fn(item, e.pos)
+	}
+}
+
+// Query returns all items within radius of center for which keep returns true.
+// A nil keep function keeps every item. The results are not sorted.
+func (g *Grid[T]) Query(center math.Vector3D, radius float64, keep func(T) bool) []Neighbour[T] {
+	out := make([]Neighbour[T], 0)
+	if len(g.items) == 0 || radius < 0 {
+		return out
+	}
+	lo := g.cellOf(math.Vector3D{X: center.X - radius, Y: center.Y - radius})
+	hi := g.cellOf(math.Vector3D{X: center.X + radius, Y: center.Y + radius})
+	lo = cell{X: maxInt(lo.X, g.min.X), Y: maxInt(lo.Y, g.min.Y)}
+	hi = cell{X: minInt(hi.X, g.max.X), Y: minInt(hi.Y, g.max.Y)}
+	for x := lo.X; x <= hi.X; x++ {
+		for y := lo.Y; y <= hi.Y; y++ {
+			for item, pos := range g.cells[cell{X: x, Y: y}] {
+				d := g.Distance(center, pos)
+				if d > radius || (keep != nil && !keep(item)) {
+					continue
+				}
+				out = append(out, Neighbour[T]{Item: item, Pos: pos, Distance: d})
+			}
+		}
+	}
+	return out
+}
+
+// Nearest returns up to k items closest to center for which keep returns true, sorted by distance.
+// Items further away than maxRadius are ignored unless maxRadius is zero or negative.
+func (g *Grid[T]) Nearest(center math.Vector3D, k int, maxRadius float64, keep func(T) bool) []Neighbour[T] {
+	out := make([]Neighbour[T], 0, k)
+	if len(g.items) == 0 || k <= 0 {
+		return out
+	}
+	limit := stdmath.Inf(1)
+	if maxRadius > 0 {
+		limit = maxRadius
+	}
+	c := g.cellOf(center)
+	// Every cell outside of ring r is at least r cells away from the center.
+	maxRing := maxInt(maxInt(c.X-g.min.X, g.max.X-c.X), maxInt(c.Y-g.min.Y, g.max.Y-c.Y))
+	for r := 0; r <= maxRing; r++ {
+		if float64(r-1)*g.cellSize > limit {
+			break
+		}
+		g.eachRingCell(c, r, func(cc cell) {
+			for item, pos := range g.cells[cc] {
+				d := g.Distance(center, pos)
+				if d > limit || (keep != nil && !keep(item)) {
+					continue
+				}
+				out = append(out, Neighbour[T]{Item: item, Pos: pos, Distance: d})
+			}
+		})
+		sort.Slice(out, func(i, j int) bool {
+			return out[i].Distance < out[j].Distance
+		})
+		if len(out) > k {
+			out = out[:k]
+		}
+		if len(out) == k && out[k-1].Distance <= float64(r)*g.cellSize {
+			break
+		}
+	}
+	return out
+}
+
+// eachRingCell calls fn for the cells on the border of the square of radius r around c.
+func (g *Grid[T]) eachRingCell(c cell, r int, fn func(cell)) {
+	if r == 0 {
+		fn(c)
+		return
+	}
+	for x := c.X - r; x <= c.X+r; x++ {
+		fn(cell{X: x, Y: c.Y - r})
+		fn(cell{X: x, Y: c.Y + r})
+	}
+	for y := c.Y - r + 1; y <= c.Y+r-1; y++ {
+		fn(cell{X: c.X - r, Y: y})
+		fn(cell{X: c.X + r, Y: y})
+	}
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,107 @@
+package spatial
+
+import (
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/WorldUnitedNFS/freeroam/math"
+)
+
+func randomGrid(n int, cellSize float64) (*Grid[int], []math.Vector3D) {
+	rng := rand.New(rand.NewSource(1))
+	g := NewGrid[int](cellSize)
+	positions := make([]math.Vector3D, n)
+	for i := range positions {
+		positions[i] = math.Vector3D{X: rng.Float64()*4000 - 2000, Y: rng.Float64()*4000 - 2000}
+		g.Update(i, positions[i])
+	}
+	return g, positions
+}
+
+func TestGridQuery(t *testing.T) {
+	g, positions := randomGrid(2000, 300)
+	center := math.Vector3D{X: 120, Y: -340}
+	got := g.Query(center, 450, nil)
+	want := 0
+	for _, pos := range positions {
+		if math.Distance3D(center, pos) <= 450 {
+			want++
+		}
+	}
+	if len(got) != want {
+		t.Errorf("Expected %d items within radius, got %d", want, len(got))
+	}
+	for _, n := range got {
+		if n.Distance > 450 {
+			t.Errorf("Item %d is %f away, outside of radius", n.Item, n.Distance)
+		}
+	}
+}
+
+func TestGridNearest(t *testing.T) {
+	g, positions := randomGrid(2000, 100)
+	center := math.Vector3D{X: -800, Y: 1500}
+	distances := make([]float64, len(positions))
+	for i, pos := range positions {
+		distances[i] = math.Distance3D(center, pos)
+	}
+	sort.Float64s(distances)
+
+	got := g.Nearest(center, 14, 0, nil)
+	if len(got) != 14 {
+		t.Fatalf("Expected 14 items, got %d", len(got))
+	}
+	for i, n := range got {
+		if n.Distance != distances[i] {
+			t.Errorf("Expected item %d to be %f away, got %f", i, distances[i], n.Distance)
+		}
+	}
+
+	limited := g.Nearest(center, 14, distances[3], nil)
+	if len(limited) != 4 {
+		t.Errorf("Expected 4 items within max radius, got %d", len(limited))
+	}
+
+	odd := g.Nearest(center, 5, 0, func(i int) bool { return i%2 == 1 })
+	for _, n := range odd {
+		if n.Item%2 != 1 {
+			t.Errorf("Expected filtered item, got %d", n.Item)
+		}
+	}
+}
+
+func TestGridUpdateRemove(t *testing.T) {
+	g := NewGrid[string](10)
+	g.Update("a", math.Vector3D{X: 5, Y: 5})
+	g.Update("a", math.Vector3D{X: 55, Y: 5})
+	if len(g.Query(math.Vector3D{X: 5, Y: 5}, 10, nil)) != 0 {
+		t.Error("Expected moved item to leave its old cell")
+	}
+	if len(g.Query(math.Vector3D{X: 55, Y: 5}, 1, nil)) != 1 {
+		t.Error("Expected moved item in its new cell")
+	}
+	g.Remove("a")
+	if g.Len() != 0 || len(g.cells) != 0 {
+		t.Errorf("Expected empty grid after removal, got %d items in %d cells", g.Len(), len(g.cells))
+	}
+}
+
+func TestGridVerticalWeight(t *testing.T) {
+	g := NewGrid[string](100)
+	g.Update("below", math.Vector3D{X: 0, Y: 0, Z: 0})
+	g.Update("beside", math.Vector3D{X: 40, Y: 0, Z: 10})
+	center := math.Vector3D{X: 0, Y: 0, Z: 20}
+
+	if got := g.Nearest(center, 1, 0, nil); got[0].Item != "below" {
+		t.Errorf("Expected Z to be ignored without a vertical weight, got %v", got[0].Item)
+	}
+	g.SetVerticalWeight(3)
+	got := g.Nearest(center, 2, 0, nil)
+	if got[0].Item != "beside" || got[1].Distance != 60 {
+		t.Errorf("Expected weighted Z to separate stacked items, got %+v", got)
+	}
+	if n := len(g.Query(center, 45, nil)); n != 0 {
+		t.Errorf("Expected weighted distances to be used for radius queries, got %d items", n)
+	}
+}
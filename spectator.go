@@ -0,0 +1,98 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/WorldUnitedNFS/freeroam/math"
+)
+
+// Spectator gives a persona the spectator role. Spectators receive slot packets like
+// everyone else, but are never put into other clients' slots and don't appear in the
+// player list.
+type Spectator struct {
+	PersonaID int `json:"personaId"`
+	// IgnoreRadius fills the spectator's slots with the closest players regardless of
+	// the visibility radius.
+	IgnoreRadius bool `json:"ignoreRadius"`
+	// Follow is the persona ID of a player whose surroundings the spectator sees instead
+	// of its own. It is ignored while that player isn't in the world.
+	Follow int `json:"follow"`
+}
+
+// SetSpectator gives a persona the spectator role, replacing its previous spectator settings.
+func (i *Server) SetSpectator(spectator Spectator) {
+	i.spectatorLock.Lock()
+	i.spectators[spectator.PersonaID] = spectator
+	i.spectatorLock.Unlock()
+	i.refreshSpectators()
+}
+
+// RemoveSpectator takes the spectator role away from a persona.
+// It returns false if the persona wasn't a spectator.
+func (i *Server) RemoveSpectator(personaID int) bool {
+	i.spectatorLock.Lock()
+	_, ok := i.spectators[personaID]
+	delete(i.spectators, personaID)
+	i.spectatorLock.Unlock()
+	i.refreshSpectators()
+	return ok
+}
+
+// Spectators returns all spectators, ordered by persona ID.
+func (i *Server) Spectators() []Spectator {
+	i.spectatorLock.RLock()
+	defer i.spectatorLock.RUnlock()
+	out := make([]Spectator, 0, len(i.spectators))
+	for _, spectator := range i.spectators {
+		out = append(out, spectator)
+	}
+	slices.SortFunc(out, func(a, b Spectator) int {
+		return cmp.Compare(a.PersonaID, b.PersonaID)
+	})
+	return out
+}
+
+func (i *Server) spectator(personaID int) *Spectator {
+	i.spectatorLock.RLock()
+	defer i.spectatorLock.RUnlock()
+	spectator, ok := i.spectators[personaID]
+	if !ok {
+		return nil
+	}
+	return &spectator
+}
+
+// refreshSpectators applies the current spectator settings to all clients.
+func (i *Server) refreshSpectators() {
+	i.forEachClient(func(client *Client) {
+		client.updateSpectator()
+	})
+}
+
+// updateSpectator looks up the client's spectator settings and publishes whether it is a spectator.
+func (c *Client) updateSpectator() {
+	if c.personaID == 0 {
+		return
+	}
+	wasSpectator := c.spectator != nil
+	c.spectator = c.shard.server.spectator(c.personaID)
+	if wasSpectator != (c.spectator != nil) {
+		c.publish()
+	}
+}
+
+// viewpoint returns the position the client's slots are filled around. For spectators
+// following another player, this is the position of that player in snap.
+func (c *Client) viewpoint(snap *worldSnapshot) math.Vector3D {
+	if c.spectator != nil && c.spectator.Follow != 0 {
+		if state, ok := snap.byPersona[c.spectator.Follow]; ok {
+			return state.Coords
+		}
+	}
+	return c.GetCoordinates()
+}
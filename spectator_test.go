@@ -0,0 +1,49 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"math/rand"
+	"testing"
+)
+
+func TestSpectators(t *testing.T) {
+	config := instantSlotsConfig(2)
+	i := newTestServerWithConfig(t, config)
+	populate(i, 4, rand.New(rand.NewSource(1)))
+	place(i, 0, 0, 0)
+	place(i, 1, 100, 0)
+	place(i, 2, 5000, 0)
+	place(i, 3, 5100, 0)
+	spectator, near, far, farther := testClient(i, 0), testClient(i, 1), testClient(i, 2), testClient(i, 3)
+
+	i.SetSpectator(Spectator{PersonaID: 1})
+	if visible := visibleTo(i, 1); visible[spectator] {
+		t.Error("Expected spectator to not be put into slots")
+	}
+	if len(i.Players()) != 3 {
+		t.Error("Expected spectator to be left out of the player list")
+	}
+	if visible := visibleTo(i, 0); !visible[near] || visible[far] {
+		t.Errorf("Expected spectator to see players within the visibility radius, got %v", visible)
+	}
+
+	i.SetSpectator(Spectator{PersonaID: 1, IgnoreRadius: true})
+	if visible := visibleTo(i, 0); !visible[near] || !visible[far] || !visible[farther] {
+		t.Errorf("Expected spectator to see players beyond the visibility radius, got %v", visible)
+	}
+
+	i.SetSpectator(Spectator{PersonaID: 1, Follow: 3})
+	if visible := visibleTo(i, 0); visible[near] || !visible[far] || !visible[farther] {
+		t.Errorf("Expected spectator to see the players around the followed player, got %v", visible)
+	}
+
+	if !i.RemoveSpectator(1) {
+		t.Error("Expected spectator to be removed")
+	}
+	if visible := visibleTo(i, 1); !visible[spectator] {
+		t.Error("Expected former spectator to be visible again")
+	}
+}
@@ -0,0 +1,129 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/WorldUnitedNFS/freeroam/math"
+)
+
+func TestVisibilityHysteresis(t *testing.T) {
+	config := instantSlotsConfig(2)
+	config.UDP.VisibilityRadius = 300
+	config.UDP.VisibilityExitRadius = 350
+	i := newTestServerWithConfig(t, config)
+	populate(i, 2, rand.New(rand.NewSource(1)))
+	other := testClient(i, 1)
+	place(i, 0, 0, 0)
+
+	steps := []struct {
+		x       float64
+		visible bool
+	}{
+		{320, false}, // Outside the enter radius
+		{290, true},
+		{320, true}, // Between the enter and exit radius
+		{360, false},
+		{320, false},
+	}
+	for _, step := range steps {
+		place(i, 1, step.x, 0)
+		if visible := visibleTo(i, 0)[other]; visible != step.visible {
+			t.Errorf("Expected visibility at %v to be %v, got %v", step.x, step.visible, visible)
+		}
+	}
+}
+
+func TestMinSlotTime(t *testing.T) {
+	config := DefaultConfig()
+	config.UDP.Workers = 2
+	config.UDP.MaxVisiblePlayers = 1
+	config.UDP.MinSlotTimeMs = 60000
+	i := newTestServerWithConfig(t, config)
+	populate(i, 3, rand.New(rand.NewSource(1)))
+	holder, newcomer := testClient(i, 1), testClient(i, 2)
+	place(i, 0, 0, 0)
+	place(i, 1, 100, 0)
+	place(i, 2, 5000, 0)
+	if visible := visibleTo(i, 0); !visible[holder] {
+		t.Fatal("Expected closest player to get the slot")
+	}
+
+	place(i, 2, 10, 0)
+	if visible := visibleTo(i, 0); !visible[holder] || visible[newcomer] {
+		t.Error("Expected player to keep its slot for the minimum slot time")
+	}
+	place(i, 1, 1000, 0)
+	if visible := visibleTo(i, 0); !visible[holder] {
+		t.Error("Expected player to keep its slot beyond the exit radius for the minimum slot time")
+	}
+	i.Ghost("", 2, time.Minute)
+	if visible := visibleTo(i, 0); visible[holder] || !visible[newcomer] {
+		t.Error("Expected player that left the world to lose its slot right away")
+	}
+}
+
+func TestVerticalVisibility(t *testing.T) {
+	for _, weight := range []float64{0, 3} {
+		config := DefaultConfig()
+		config.UDP.Workers = 2
+		config.UDP.VisibilityRadius = 300
+		config.UDP.VerticalWeight = weight
+		i := newTestServerWithConfig(t, config)
+		populate(i, 3, rand.New(rand.NewSource(1)))
+		placeAt(i, 0, math.Vector3D{X: 0, Y: 0, Z: 20})
+		placeAt(i, 1, math.Vector3D{X: 10, Y: 0, Z: -130})
+		placeAt(i, 2, math.Vector3D{X: 200, Y: 0, Z: 20})
+
+		visible := visibleTo(i, 0)
+		if below := visible[testClient(i, 1)]; below != (weight == 0) {
+			t.Errorf("Expected visibility of the player on the road below with weight %v to be %v, got %v", weight, weight == 0, below)
+		}
+		if !visible[testClient(i, 2)] {
+			t.Errorf("Expected player on the same level to be visible with weight %v", weight)
+		}
+	}
+}
+
+func TestPendingQueueOrder(t *testing.T) {
+	i := newTestServerWithConfig(t, instantSlotsConfig(2))
+	populate(i, 4, rand.New(rand.NewSource(1)))
+	place(i, 0, 0, 0)
+	place(i, 1, 250, 0)
+	place(i, 2, 3000, 0)
+	place(i, 3, 3000, 0)
+	far, near, mate := testClient(i, 1), testClient(i, 2), testClient(i, 3)
+
+	recalculate := func() (queue []*Client) {
+		i.buildSnapshot()
+		i.execClient(testAddr(0).String(), func(client *Client) {
+			client.recalculateSlots()
+			queue = append(queue, client.pendingPlayerQueue...)
+		})
+		return queue
+	}
+	if queue := recalculate(); len(queue) != 1 || queue[0] != far {
+		t.Fatalf("Expected only the far player to be pending, got %v", queue)
+	}
+
+	// Personas are numbered from 1, so persona 4 is testAddr(3).
+	i.SetGroup(Group{ID: "party:1", Members: []int{1, 4}})
+	place(i, 2, 10, 0)
+	place(i, 3, 200, 0)
+	queue := recalculate()
+	if len(queue) != 3 || queue[0] != mate || queue[1] != near || queue[2] != far {
+		t.Fatalf("Expected the groupmate, then the closest player to be pending first, got %v", queue)
+	}
+
+	i.execClient(testAddr(0).String(), func(client *Client) {
+		client.processNextPendingPlayer()
+	})
+	if slots := slotClients(i, 0); slots[0] != mate || slots[1] != nil {
+		t.Errorf("Expected the groupmate to spawn first, got %v", slots)
+	}
+}
@@ -0,0 +1,105 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"time"
+
+	"github.com/WorldUnitedNFS/freeroam/math"
+)
+
+// ZoneConfig defines an area of the map with its own visibility settings.
+// The area is the polygon given by Points, or the rectangle from Min to Max if there are
+// fewer than three points. Settings that are 0 keep the server-wide value.
+type ZoneConfig struct {
+	Name   string
+	Min    math.Vector2D
+	Max    math.Vector2D
+	Points []math.Vector2D
+
+	VisibilityRadius float64
+	// VisibilityExitRadius defaults to VisibilityRadius plus the server-wide difference
+	// between the exit and enter radius.
+	VisibilityExitRadius float64
+	// MaxVisiblePlayers can't be larger than the number of slots of a client.
+	MaxVisiblePlayers  int
+	PlayerSpawnDelayMs int
+}
+
+// contains returns true if pos lies within the zone.
+func (z *ZoneConfig) contains(pos math.Vector2D) bool {
+	if len(z.Points) < 3 {
+		return pos.X >= z.Min.X && pos.X <= z.Max.X && pos.Y >= z.Min.Y && pos.Y <= z.Max.Y
+	}
+	// Count how many edges a ray from pos in the +X direction crosses.
+	inside := false
+	for i, j := 0, len(z.Points)-1; i < len(z.Points); j, i = i, i+1 {
+		a, b := z.Points[i], z.Points[j]
+		if (a.Y > pos.Y) != (b.Y > pos.Y) && pos.X < (b.X-a.X)*(pos.Y-a.Y)/(b.Y-a.Y)+a.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// zoneAt returns the first configured zone containing pos, or nil if there is none.
+func (i *Server) zoneAt(pos math.Vector2D) *ZoneConfig {
+	for n := range i.zones {
+		if i.zones[n].contains(pos) {
+			return &i.zones[n]
+		}
+	}
+	return nil
+}
+
+// Zone returns the name of the zone the client was in at its last position update,
+// or an empty string if it isn't in a zone.
+func (c *Client) Zone() string {
+	return c.State().Zone
+}
+
+func (c *Client) zoneName() string {
+	if c.zone == nil {
+		return ""
+	}
+	return c.zone.Name
+}
+
+// updateZone looks up the zone of the client's current position.
+func (c *Client) updateZone() {
+	c.zone = c.shard.server.zoneAt(c.carPos.Pos())
+}
+
+// radii returns the enter and exit radius for the client's current zone.
+func (c *Client) radii() (float64, float64) {
+	if c.zone == nil || c.zone.VisibilityRadius <= 0 {
+		return c.visibilityRadius, c.exitRadius
+	}
+	enter, exit := c.zone.VisibilityRadius, c.zone.VisibilityExitRadius
+	if exit <= 0 {
+		exit = enter + c.exitRadius - c.visibilityRadius
+	}
+	if exit < enter {
+		exit = enter
+	}
+	return enter, exit
+}
+
+// slotLimit returns how many of the client's slots may be used in its current zone.
+func (c *Client) slotLimit() int {
+	if c.zone == nil || c.zone.MaxVisiblePlayers <= 0 {
+		return c.slotCapacity
+	}
+	return min(c.zone.MaxVisiblePlayers, c.slotCapacity)
+}
+
+// spawnDelay returns the time between two player spawns for the client's current zone.
+func (c *Client) spawnDelay() time.Duration {
+	delayMs := c.playerSpawnDelayMs
+	if c.zone != nil && c.zone.PlayerSpawnDelayMs > 0 {
+		delayMs = c.zone.PlayerSpawnDelayMs
+	}
+	return time.Duration(delayMs) * time.Millisecond
+}
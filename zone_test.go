@@ -0,0 +1,85 @@
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package freeroam
+
+import (
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/WorldUnitedNFS/freeroam/math"
+)
+
+func TestZoneContains(t *testing.T) {
+	rect := ZoneConfig{Min: math.Vector2D{X: -10, Y: -10}, Max: math.Vector2D{X: 10, Y: 10}}
+	// An L shape, which is concave at (5, 5).
+	polygon := ZoneConfig{Points: []math.Vector2D{
+		{X: 0, Y: 0}, {X: 10, Y: 0}, {X: 10, Y: 4}, {X: 4, Y: 4}, {X: 4, Y: 10}, {X: 0, Y: 10},
+	}}
+	tests := []struct {
+		zone   *ZoneConfig
+		pos    math.Vector2D
+		inside bool
+	}{
+		{&rect, math.Vector2D{X: 0, Y: 0}, true},
+		{&rect, math.Vector2D{X: 10, Y: -10}, true},
+		{&rect, math.Vector2D{X: 11, Y: 0}, false},
+		{&polygon, math.Vector2D{X: 2, Y: 8}, true},
+		{&polygon, math.Vector2D{X: 8, Y: 2}, true},
+		{&polygon, math.Vector2D{X: 5, Y: 5}, false},
+		{&polygon, math.Vector2D{X: -1, Y: 2}, false},
+	}
+	for _, test := range tests {
+		if inside := test.zone.contains(test.pos); inside != test.inside {
+			t.Errorf("Expected %v inside %+v to be %v", test.pos, test.zone, test.inside)
+		}
+	}
+}
+
+func TestZones(t *testing.T) {
+	config := instantSlotsConfig(2)
+	config.UDP.VisibilityRadius = 300
+	config.UDP.VisibilityExitRadius = 350
+	config.Zones = []ZoneConfig{{
+		Name:               "downtown",
+		Min:                math.Vector2D{X: -500, Y: -500},
+		Max:                math.Vector2D{X: 500, Y: 500},
+		VisibilityRadius:   100,
+		MaxVisiblePlayers:  1,
+		PlayerSpawnDelayMs: 1000,
+	}}
+	i := newTestServerWithConfig(t, config)
+	populate(i, 3, rand.New(rand.NewSource(1)))
+	place(i, 0, 0, 0)
+	place(i, 1, 50, 0)
+	place(i, 2, 80, 0)
+	client, closest := testClient(i, 0), testClient(i, 1)
+
+	i.buildSnapshot()
+	if zone := client.Zone(); zone != "downtown" {
+		t.Errorf("Expected client to be in downtown, got %q", zone)
+	}
+	i.execClient(testAddr(0).String(), func(client *Client) {
+		if enter, exit := client.radii(); enter != 100 || exit != 150 {
+			t.Errorf("Expected zone radii of 100 and 150, got %v and %v", enter, exit)
+		}
+		if delay := client.spawnDelay(); delay != time.Second {
+			t.Errorf("Expected zone spawn delay of 1s, got %v", delay)
+		}
+	})
+	if visible := visibleTo(i, 0); len(visible) != 1 || !visible[closest] {
+		t.Errorf("Expected only the closest player to be visible in the zone, got %v", visible)
+	}
+
+	place(i, 0, 600, 0)
+	place(i, 1, 650, 0)
+	place(i, 2, 680, 0)
+	if zone := client.Zone(); zone != "" {
+		t.Errorf("Expected client to have left the zone, got %q", zone)
+	}
+	if visible := visibleTo(i, 0); len(visible) != 2 {
+		t.Errorf("Expected server-wide settings outside of the zone, got %d visible players", len(visible))
+	}
+}